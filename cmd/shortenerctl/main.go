@@ -0,0 +1,388 @@
+// Command shortenerctl is a command line client for the shortener gRPC API.
+//
+//	shortenerctl [flags] shorten [-domain D] [-password P] [-interstitial] [-title T] [-description D] [-tags a,b] [URL...]
+//	shortenerctl [flags] resolve [-domain D] [-password P] [CODE...]
+//	shortenerctl [flags] delete [-domain D] [CODE...]
+//	shortenerctl [flags] list [-domain D] [-owner ID] [-tag T] [-sort created|clicks] [-limit N] [-all]
+//	shortenerctl [flags] stats [-domain D] [-password P] [CODE...]
+//
+// Without URL or CODE arguments they are read from stdin, one per line.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+)
+
+const defaultAddr = "localhost:6969"
+
+func main() {
+	flag.Usage = usage
+	addr := flag.String("addr", envOr("SHORTENER_ADDR", defaultAddr), "gRPC server address (env SHORTENER_ADDR)")
+	apiKey := flag.String("api-key", os.Getenv("SHORTENER_API_KEY"), "API key for shorten, delete and list (env SHORTENER_API_KEY)")
+	timeout := flag.Duration("timeout", 10*time.Second, "Deadline of each call")
+	output := flag.String("o", envOr("SHORTENER_OUTPUT", "table"), "Output format: 'table' or 'json' (env SHORTENER_OUTPUT)")
+	useTLS := flag.Bool("tls", envOr("SHORTENER_TLS", "") != "", "Connect over TLS, implied by the other TLS flags (env SHORTENER_TLS)")
+	caFile := flag.String("ca-file", os.Getenv("SHORTENER_CA_FILE"), "PEM bundle of CAs to verify the server with instead of the system roots (env SHORTENER_CA_FILE)")
+	certFile := flag.String("cert", os.Getenv("SHORTENER_CERT"), "PEM client certificate, for servers with -grpc-client-auth (env SHORTENER_CERT)")
+	keyFile := flag.String("key", os.Getenv("SHORTENER_KEY"), "PEM private key of -cert (env SHORTENER_KEY)")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	creds := insecure.NewCredentials()
+	if *useTLS || *caFile != "" || *certFile != "" || *keyFile != "" {
+		cfg, err := tlsConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	c := &client{
+		stub:    shortener_v0.NewShortenerV0Client(conn),
+		apiKey:  *apiKey,
+		timeout: *timeout,
+		out:     out,
+	}
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "shorten":
+		err = c.shorten(args)
+	case "resolve":
+		err = c.resolve(args)
+	case "delete":
+		err = c.delete(args)
+	case "list":
+		err = c.list(args)
+	case "stats":
+		err = c.stats(args)
+	default:
+		err = fmt.Errorf("unknown command %q", flag.Arg(0))
+	}
+	if flushErr := out.flush(); err == nil {
+		err = flushErr
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %[1]s [flags] <command> [args]
+
+Commands:
+  shorten [URL...]   create short links
+  resolve [CODE...]  print the original URLs of short codes
+  delete [CODE...]   delete short links created with the API key
+  list               list links page by page
+  stats [CODE...]    print click counts and details of short codes
+
+URLs and codes are read from stdin, one per line, when none are given.
+Run "%[1]s <command> -h" for command flags.
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+type client struct {
+	stub    shortener_v0.ShortenerV0Client
+	apiKey  string
+	timeout time.Duration
+	out     printer
+}
+
+func (c *client) call(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if c.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.apiKey)
+	}
+	return fn(ctx)
+}
+
+func (c *client) shorten(args []string) error {
+	fs := flag.NewFlagSet("shorten", flag.ExitOnError)
+	domain := fs.String("domain", "", "Short domain, the server default if empty")
+	password := fs.String("password", "", "Protect the links with a password")
+	interstitial := fs.Bool("interstitial", false, "Always show a preview page before redirecting")
+	title := fs.String("title", "", "Link title")
+	description := fs.String("description", "", "Link description")
+	tags := fs.String("tags", "", "Comma separated tags")
+	fs.Parse(args)
+
+	req := &shortener_v0.Request{
+		Domain:       *domain,
+		Password:     *password,
+		Interstitial: *interstitial,
+		Title:        *title,
+		Description:  *description,
+	}
+	if *tags != "" {
+		req.Tags = strings.Split(*tags, ",")
+	}
+
+	c.out.header("URL", "CODE")
+	return eachInput(fs.Args(), func(url string) error {
+		return c.call(func(ctx context.Context) error {
+			req.Url = url
+			resp, err := c.stub.GetShortUrl(ctx, req)
+			if err != nil {
+				return err
+			}
+			c.out.row(struct {
+				URL  string `json:"url"`
+				Code string `json:"code"`
+			}{url, resp.GetUrl()}, url, resp.GetUrl())
+			return nil
+		})
+	})
+}
+
+func (c *client) resolve(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	domain := fs.String("domain", "", "Short domain, the server default if empty")
+	password := fs.String("password", "", "Password of protected links")
+	fs.Parse(args)
+
+	c.out.header("CODE", "URL")
+	return eachInput(fs.Args(), func(code string) error {
+		return c.call(func(ctx context.Context) error {
+			resp, err := c.stub.ResolveUrl(ctx, &shortener_v0.Request{Url: code, Domain: *domain, Password: *password})
+			if err != nil {
+				return err
+			}
+			c.out.row(struct {
+				Code string `json:"code"`
+				URL  string `json:"url"`
+			}{code, resp.GetUrl()}, code, resp.GetUrl())
+			return nil
+		})
+	})
+}
+
+func (c *client) delete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	domain := fs.String("domain", "", "Short domain, the server default if empty")
+	fs.Parse(args)
+
+	c.out.header("CODE", "STATUS")
+	return eachInput(fs.Args(), func(code string) error {
+		return c.call(func(ctx context.Context) error {
+			if _, err := c.stub.DeleteShortUrl(ctx, &shortener_v0.Request{Url: code, Domain: *domain}); err != nil {
+				return err
+			}
+			c.out.row(struct {
+				Code    string `json:"code"`
+				Deleted bool   `json:"deleted"`
+			}{code, true}, code, "deleted")
+			return nil
+		})
+	})
+}
+
+func (c *client) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	domain := fs.String("domain", "", "Substring of the short domain name; the hosts of the original URLs are not searched")
+	owner := fs.String("owner", "", "API key ID of the link owner, the own key if empty; other owners need an admin key")
+	tag := fs.String("tag", "", "Tag")
+	sort := fs.String("sort", "created", "Order: 'created' or 'clicks'")
+	limit := fs.Int("limit", 50, "Page size")
+	cursor := fs.String("cursor", "", "Cursor printed after the previous page")
+	all := fs.Bool("all", false, "Follow cursors and print every page")
+	fs.Parse(args)
+
+	req := &shortener_v0.ListLinksRequest{
+		ShortDomain: *domain,
+		Owner:       *owner,
+		Tag:         *tag,
+		Sort:        *sort,
+		Limit:       int32(*limit),
+		Cursor:      *cursor,
+	}
+	c.out.header(linkColumns...)
+	for {
+		var resp *shortener_v0.ListLinksResponse
+		err := c.call(func(ctx context.Context) (err error) {
+			resp, err = c.stub.ListLinks(ctx, req)
+			return err
+		})
+		if err != nil {
+			return describe(err)
+		}
+		for _, link := range resp.GetLinks() {
+			c.printLink(link)
+		}
+
+		if resp.GetNextCursor() == "" {
+			return nil
+		}
+		if !*all {
+			if err := c.out.flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "more links: -cursor %s\n", resp.GetNextCursor())
+			return nil
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+}
+
+func (c *client) stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	domain := fs.String("domain", "", "Short domain, the server default if empty")
+	password := fs.String("password", "", "Password of protected links")
+	fs.Parse(args)
+
+	c.out.header(linkColumns...)
+	return eachInput(fs.Args(), func(code string) error {
+		return c.call(func(ctx context.Context) error {
+			link, err := c.stub.GetLink(ctx, &shortener_v0.Request{Url: code, Domain: *domain, Password: *password})
+			if err != nil {
+				return err
+			}
+			c.printLink(link)
+			return nil
+		})
+	})
+}
+
+var linkColumns = []string{"SHORT URL", "CLICKS", "CREATED", "TAGS", "URL"}
+
+func (c *client) printLink(link *shortener_v0.Link) {
+	created := link.GetCreatedAt().AsTime().Format(time.RFC3339)
+	origin := link.GetOriginUrl()
+	if origin == "" && link.GetProtected() {
+		origin = "(password protected)"
+	}
+	c.out.row(struct {
+		Domain       string   `json:"domain"`
+		Code         string   `json:"code"`
+		ShortURL     string   `json:"short_url"`
+		OriginURL    string   `json:"origin_url,omitempty"`
+		Title        string   `json:"title,omitempty"`
+		Description  string   `json:"description,omitempty"`
+		Owner        string   `json:"owner,omitempty"`
+		CreatedAt    string   `json:"created_at"`
+		Clicks       int64    `json:"clicks"`
+		Tags         []string `json:"tags,omitempty"`
+		Protected    bool     `json:"protected,omitempty"`
+		Interstitial bool     `json:"interstitial,omitempty"`
+	}{
+		link.GetDomain(), link.GetShortCode(), link.GetShortUrl(), link.GetOriginUrl(), link.GetTitle(),
+		link.GetDescription(), link.GetOwner(), created, link.GetClicks(), link.GetTags(),
+		link.GetProtected(), link.GetInterstitial(),
+	}, link.GetShortUrl(), fmt.Sprint(link.GetClicks()), created, strings.Join(link.GetTags(), ","), origin)
+}
+
+// eachInput calls fn for every argument, or for every non-empty stdin line
+// when there are none. Failures are reported and do not stop the loop.
+func eachInput(args []string, fn func(string) error) error {
+	var failed int
+	handle := func(v string) {
+		if err := fn(v); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", v, describe(err))
+			failed++
+		}
+	}
+
+	if len(args) > 0 {
+		for _, arg := range args {
+			handle(arg)
+		}
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				handle(line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d failed", failed)
+	}
+	return nil
+}
+
+// describe turns gRPC errors into "Code: message".
+func describe(err error) error {
+	if st, ok := status.FromError(err); ok {
+		return fmt.Errorf("%s: %s", st.Code(), st.Message())
+	}
+	return err
+}
+
+// tlsConfig verifies the server with the CAs of caFile, the system roots
+// if empty, and presents the client certificate if one is given.
+func tlsConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("-cert and -key must be given together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes results as an aligned table or as JSON, one object per line.
+type printer interface {
+	header(columns ...string)
+	// row prints v as JSON or the column values as a table row
+	row(v any, columns ...string)
+	flush() error
+}
+
+func newPrinter(w io.Writer, format string) (printer, error) {
+	switch format {
+	case "table":
+		return newTablePrinter(w), nil
+	case "json":
+		return newJSONPrinter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: use 'table' or 'json'", format)
+	}
+}
+
+// tablePrinter prints the header before the first row only, so empty
+// results print nothing.
+type tablePrinter struct {
+	tw      *tabwriter.Writer
+	columns []string
+	rows    int
+}
+
+func newTablePrinter(w io.Writer) *tablePrinter {
+	return &tablePrinter{tw: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+}
+
+func (p *tablePrinter) header(columns ...string) {
+	p.columns = columns
+}
+
+func (p *tablePrinter) row(_ any, columns ...string) {
+	if p.rows == 0 && len(p.columns) > 0 {
+		fmt.Fprintln(p.tw, strings.Join(p.columns, "\t"))
+	}
+	p.rows++
+	fmt.Fprintln(p.tw, strings.Join(columns, "\t"))
+}
+
+func (p *tablePrinter) flush() error {
+	return p.tw.Flush()
+}
+
+type jsonPrinter struct {
+	enc *json.Encoder
+	err error
+}
+
+func newJSONPrinter(w io.Writer) *jsonPrinter {
+	return &jsonPrinter{enc: json.NewEncoder(w)}
+}
+
+func (p *jsonPrinter) header(...string) {}
+
+func (p *jsonPrinter) row(v any, _ ...string) {
+	if err := p.enc.Encode(v); err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *jsonPrinter) flush() error {
+	return p.err
+}
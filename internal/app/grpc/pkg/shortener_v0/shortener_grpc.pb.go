@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.2.0
-// - protoc             v3.12.4
+// - protoc             (unknown)
 // source: shortener.proto
 
 package shortener_v0
@@ -24,6 +24,22 @@ const _ = grpc.SupportPackageIsVersion7
 type ShortenerV0Client interface {
 	GetShortUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	GetOriginUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Deletes the short code passed in Request.url. Requires the API key
+	// which created the link; returns the deleted code.
+	DeleteShortUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Resolves a short code like GetOriginUrl, checking Request.password
+	// for password protected links.
+	ResolveUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Renders a QR code for the full short URL of an existing code.
+	GetQrCode(ctx context.Context, in *QrCodeRequest, opts ...grpc.CallOption) (*QrCodeResponse, error)
+	// Returns the details and click count of a link. Password protected
+	// links need Request.password like in ResolveUrl.
+	GetLink(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Link, error)
+	// Lists links page by page. Requires an API key.
+	ListLinks(ctx context.Context, in *ListLinksRequest, opts ...grpc.CallOption) (*ListLinksResponse, error)
+	// Changes the metadata of a link. Requires the API key which created
+	// the link.
+	UpdateLink(ctx context.Context, in *UpdateLinkRequest, opts ...grpc.CallOption) (*Link, error)
 }
 
 type shortenerV0Client struct {
@@ -52,12 +68,82 @@ func (c *shortenerV0Client) GetOriginUrl(ctx context.Context, in *Request, opts
 	return out, nil
 }
 
+func (c *shortenerV0Client) DeleteShortUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/DeleteShortUrl", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV0Client) ResolveUrl(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/ResolveUrl", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV0Client) GetQrCode(ctx context.Context, in *QrCodeRequest, opts ...grpc.CallOption) (*QrCodeResponse, error) {
+	out := new(QrCodeResponse)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/GetQrCode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV0Client) GetLink(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Link, error) {
+	out := new(Link)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/GetLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV0Client) ListLinks(ctx context.Context, in *ListLinksRequest, opts ...grpc.CallOption) (*ListLinksResponse, error) {
+	out := new(ListLinksResponse)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/ListLinks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV0Client) UpdateLink(ctx context.Context, in *UpdateLinkRequest, opts ...grpc.CallOption) (*Link, error) {
+	out := new(Link)
+	err := c.cc.Invoke(ctx, "/shortener_v0.ShortenerV0/UpdateLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerV0Server is the server API for ShortenerV0 service.
 // All implementations must embed UnimplementedShortenerV0Server
 // for forward compatibility
 type ShortenerV0Server interface {
 	GetShortUrl(context.Context, *Request) (*Response, error)
 	GetOriginUrl(context.Context, *Request) (*Response, error)
+	// Deletes the short code passed in Request.url. Requires the API key
+	// which created the link; returns the deleted code.
+	DeleteShortUrl(context.Context, *Request) (*Response, error)
+	// Resolves a short code like GetOriginUrl, checking Request.password
+	// for password protected links.
+	ResolveUrl(context.Context, *Request) (*Response, error)
+	// Renders a QR code for the full short URL of an existing code.
+	GetQrCode(context.Context, *QrCodeRequest) (*QrCodeResponse, error)
+	// Returns the details and click count of a link. Password protected
+	// links need Request.password like in ResolveUrl.
+	GetLink(context.Context, *Request) (*Link, error)
+	// Lists links page by page. Requires an API key.
+	ListLinks(context.Context, *ListLinksRequest) (*ListLinksResponse, error)
+	// Changes the metadata of a link. Requires the API key which created
+	// the link.
+	UpdateLink(context.Context, *UpdateLinkRequest) (*Link, error)
 	mustEmbedUnimplementedShortenerV0Server()
 }
 
@@ -71,6 +157,24 @@ func (UnimplementedShortenerV0Server) GetShortUrl(context.Context, *Request) (*R
 func (UnimplementedShortenerV0Server) GetOriginUrl(context.Context, *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOriginUrl not implemented")
 }
+func (UnimplementedShortenerV0Server) DeleteShortUrl(context.Context, *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteShortUrl not implemented")
+}
+func (UnimplementedShortenerV0Server) ResolveUrl(context.Context, *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveUrl not implemented")
+}
+func (UnimplementedShortenerV0Server) GetQrCode(context.Context, *QrCodeRequest) (*QrCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQrCode not implemented")
+}
+func (UnimplementedShortenerV0Server) GetLink(context.Context, *Request) (*Link, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLink not implemented")
+}
+func (UnimplementedShortenerV0Server) ListLinks(context.Context, *ListLinksRequest) (*ListLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLinks not implemented")
+}
+func (UnimplementedShortenerV0Server) UpdateLink(context.Context, *UpdateLinkRequest) (*Link, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLink not implemented")
+}
 func (UnimplementedShortenerV0Server) mustEmbedUnimplementedShortenerV0Server() {}
 
 // UnsafeShortenerV0Server may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +224,114 @@ func _ShortenerV0_GetOriginUrl_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShortenerV0_DeleteShortUrl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).DeleteShortUrl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/DeleteShortUrl",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).DeleteShortUrl(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV0_ResolveUrl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).ResolveUrl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/ResolveUrl",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).ResolveUrl(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV0_GetQrCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QrCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).GetQrCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/GetQrCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).GetQrCode(ctx, req.(*QrCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV0_GetLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).GetLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/GetLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).GetLink(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV0_ListLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).ListLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/ListLinks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).ListLinks(ctx, req.(*ListLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV0_UpdateLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV0Server).UpdateLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener_v0.ShortenerV0/UpdateLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV0Server).UpdateLink(ctx, req.(*UpdateLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShortenerV0_ServiceDesc is the grpc.ServiceDesc for ShortenerV0 service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,6 +347,30 @@ var ShortenerV0_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOriginUrl",
 			Handler:    _ShortenerV0_GetOriginUrl_Handler,
 		},
+		{
+			MethodName: "DeleteShortUrl",
+			Handler:    _ShortenerV0_DeleteShortUrl_Handler,
+		},
+		{
+			MethodName: "ResolveUrl",
+			Handler:    _ShortenerV0_ResolveUrl_Handler,
+		},
+		{
+			MethodName: "GetQrCode",
+			Handler:    _ShortenerV0_GetQrCode_Handler,
+		},
+		{
+			MethodName: "GetLink",
+			Handler:    _ShortenerV0_GetLink_Handler,
+		},
+		{
+			MethodName: "ListLinks",
+			Handler:    _ShortenerV0_ListLinks_Handler,
+		},
+		{
+			MethodName: "UpdateLink",
+			Handler:    _ShortenerV0_UpdateLink_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yihleego/base62"
+)
+
+var (
+	ErrUnauthenticated = errors.New("valid API key required")
+	ErrForbidden       = errors.New("operation not permitted for this API key")
+	ErrKeyNotFound     = errors.New("API key not found")
+	ErrKeyRevoked      = errors.New("API key revoked")
+)
+
+const (
+	tokenPrefix  = "usk_"
+	minTokenLen  = 16
+	bearerPrefix = "Bearer "
+)
+
+// Key describes an API key. The plaintext token is only known at creation
+// time; stores keep its SHA-256 hash.
+type Key struct {
+	ID   string
+	Name string
+	Hash string
+	// Admin keys may list the links of every owner, reload the server
+	// configuration and read the gRPC metrics
+	Admin     bool
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// KeyStore persists API keys.
+type KeyStore interface {
+	// Saves a new key. Returns an error if a key with the same ID or hash exists.
+	SaveKey(key Key) error
+
+	// Returns the key with the given token hash.
+	// Returns ErrKeyNotFound if there is no such key
+	GetKeyByHash(hash string) (Key, error)
+
+	// Marks the key as revoked.
+	// Returns ErrKeyNotFound if there is no such key
+	RevokeKey(id string) error
+
+	ListKeys() ([]Key, error)
+}
+
+// Authenticator issues and verifies API keys.
+type Authenticator struct {
+	store KeyStore
+}
+
+func NewAuthenticator(store KeyStore) *Authenticator {
+	return &Authenticator{store: store}
+}
+
+// CreateKey generates a new API key and returns it together with the
+// plaintext token, which must be handed to the client and cannot be
+// recovered later.
+func (a *Authenticator) CreateKey(name string, admin bool) (Key, string, error) {
+	var secret [24]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	token := tokenPrefix + base62.StdEncoding.EncodeToString(secret[:])
+
+	key, err := a.register(name, token, admin)
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key, token, nil
+}
+
+// ImportKey registers an externally generated token, e.g. a bootstrap key
+// passed through the environment. Importing an already known token is a no-op.
+func (a *Authenticator) ImportKey(name, token string) (Key, error) {
+	if len(token) < minTokenLen {
+		return Key{}, fmt.Errorf("API key must be at least %d characters long", minTokenLen)
+	}
+	if key, err := a.store.GetKeyByHash(HashToken(token)); err == nil {
+		return key, nil
+	}
+	return a.register(name, token, false)
+}
+
+func (a *Authenticator) register(name, token string, admin bool) (Key, error) {
+	var id [6]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return Key{}, fmt.Errorf("failed to generate API key id: %w", err)
+	}
+
+	key := Key{
+		ID:        hex.EncodeToString(id[:]),
+		Name:      name,
+		Hash:      HashToken(token),
+		Admin:     admin,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.store.SaveKey(key); err != nil {
+		return Key{}, fmt.Errorf("failed to save API key: %w", err)
+	}
+	return key, nil
+}
+
+func (a *Authenticator) RevokeKey(id string) error {
+	return a.store.RevokeKey(id)
+}
+
+func (a *Authenticator) ListKeys() ([]Key, error) {
+	return a.store.ListKeys()
+}
+
+// Authenticate returns the active key matching token.
+// Returns ErrUnauthenticated for unknown or revoked keys.
+func (a *Authenticator) Authenticate(token string) (Key, error) {
+	if token == "" {
+		return Key{}, ErrUnauthenticated
+	}
+
+	key, err := a.store.GetKeyByHash(HashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return Key{}, ErrUnauthenticated
+		}
+		return Key{}, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key.Revoked() {
+		return Key{}, fmt.Errorf("%w: %w", ErrUnauthenticated, ErrKeyRevoked)
+	}
+	return key, nil
+}
+
+// HashToken returns the hex encoded SHA-256 of the token. API keys are long
+// random strings, so a fast hash is enough to make a leaked table useless.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenFromAuthorization extracts the token from an "Authorization: Bearer <token>"
+// header value. The bare token is accepted as well.
+func TokenFromAuthorization(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(value[len(bearerPrefix):])
+	}
+	return value
+}
+
+type keyCtxKey struct{}
+
+// WithKey stores the authenticated key in ctx.
+func WithKey(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, keyCtxKey{}, key)
+}
+
+// KeyFromContext returns the authenticated key stored in ctx.
+func KeyFromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyCtxKey{}).(Key)
+	return key, ok
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InMemoryKeyStore keeps API keys in process memory. Keys are lost on restart.
+type InMemoryKeyStore struct {
+	mu     sync.RWMutex
+	byID   map[string]Key
+	byHash map[string]string
+}
+
+func NewInMemoryKeyStore() *InMemoryKeyStore {
+	return &InMemoryKeyStore{
+		byID:   make(map[string]Key),
+		byHash: make(map[string]string),
+	}
+}
+
+func (s *InMemoryKeyStore) SaveKey(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[key.ID]; ok {
+		return fmt.Errorf("API key '%s' already exists", key.ID)
+	}
+	if _, ok := s.byHash[key.Hash]; ok {
+		return fmt.Errorf("API key with the same token already exists")
+	}
+
+	s.byID[key.ID] = key
+	s.byHash[key.Hash] = key.ID
+	return nil
+}
+
+func (s *InMemoryKeyStore) GetKeyByHash(hash string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byHash[hash]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return s.byID[id], nil
+}
+
+func (s *InMemoryKeyStore) RevokeKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.byID[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+		s.byID[id] = key
+	}
+	return nil
+}
+
+func (s *InMemoryKeyStore) ListKeys() ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]Key, 0, len(s.byID))
+	for _, key := range s.byID {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+var _ KeyStore = (*InMemoryKeyStore)(nil)
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresKeyStore keeps API keys in the api_keys table.
+type PostgresKeyStore struct {
+	pool *pgxpool.Pool
+	ctx  context.Context
+}
+
+func NewPostgresKeyStore(ctx context.Context, pool *pgxpool.Pool) (*PostgresKeyStore, error) {
+	store := &PostgresKeyStore{
+		pool: pool,
+		ctx:  ctx,
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+			id         VARCHAR(32) PRIMARY KEY,
+			name       TEXT NOT NULL,
+			key_hash   CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			revoked_at TIMESTAMPTZ
+	);
+	ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS admin BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+	return store, nil
+}
+
+func (s *PostgresKeyStore) SaveKey(key Key) error {
+	query := `INSERT INTO api_keys (id, name, key_hash, admin, created_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := s.pool.Exec(s.ctx, query, key.ID, key.Name, key.Hash, key.Admin, key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save API key to postgres: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresKeyStore) GetKeyByHash(hash string) (Key, error) {
+	var key Key
+	query := `SELECT id, name, key_hash, admin, created_at, revoked_at FROM api_keys WHERE key_hash = $1`
+	err := s.pool.QueryRow(s.ctx, query, hash).Scan(&key.ID, &key.Name, &key.Hash, &key.Admin, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Key{}, ErrKeyNotFound
+		}
+		return Key{}, fmt.Errorf("failed to get API key from psql: %w", err)
+	}
+	return key, nil
+}
+
+func (s *PostgresKeyStore) RevokeKey(id string) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`
+	tag, err := s.pool.Exec(s.ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func (s *PostgresKeyStore) ListKeys() ([]Key, error) {
+	rows, err := s.pool.Query(s.ctx, `SELECT id, name, key_hash, admin, created_at, revoked_at FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var key Key
+		if err := rows.Scan(&key.ID, &key.Name, &key.Hash, &key.Admin, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+var _ KeyStore = (*PostgresKeyStore)(nil)
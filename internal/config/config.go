@@ -0,0 +1,207 @@
+// Package config reads settings from a file in addition to the command
+// line and reloads them at runtime.
+//
+// The file sets flags by name, one "name = value" per line; empty lines
+// and lines starting with # are ignored. Flags given on the command line
+// take precedence over the file.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PrepareFunc validates a new value of a setting and returns the function
+// applying it. Nothing is applied unless all changed settings validate.
+type PrepareFunc func(value string) (apply func(), err error)
+
+// Change is a setting whose value differs from the running one.
+type Change struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Result lists the changes found by a reload.
+type Result struct {
+	// Changes in effect now
+	Applied []Change `json:"applied"`
+	// Changes of settings which are only read at startup
+	RestartRequired []Change `json:"restart_required"`
+}
+
+// Config tracks the flag values the application runs with.
+type Config struct {
+	fs      *flag.FlagSet
+	path    string
+	cmdline map[string]bool
+
+	mu         sync.Mutex
+	reloadable map[string]PrepareFunc
+	current    map[string]string
+}
+
+// Load sets the flags of the parsed fs which are not given on the command
+// line from the file at path. An empty path only records the flag values.
+func Load(fs *flag.FlagSet, path string) (*Config, error) {
+	c := &Config{
+		fs:         fs,
+		path:       path,
+		cmdline:    map[string]bool{},
+		reloadable: map[string]PrepareFunc{},
+		current:    map[string]string{},
+	}
+	fs.Visit(func(f *flag.Flag) { c.cmdline[f.Name] = true })
+
+	if path != "" {
+		settings, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range settings {
+			if c.cmdline[name] {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) { c.current[f.Name] = f.Value.String() })
+	return c, nil
+}
+
+// read parses the settings file.
+func (c *Config) read() (map[string]string, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(text, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected name = value", c.path, line)
+		}
+		if c.fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", c.path, line, name)
+		}
+		if name == "config" {
+			return nil, fmt.Errorf("%s:%d: config cannot be set in the file", c.path, line)
+		}
+		settings[name] = value
+	}
+	return settings, scanner.Err()
+}
+
+// Reloadable registers a setting which can change at runtime.
+func (c *Config) Reloadable(name string, prepare PrepareFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloadable[name] = prepare
+}
+
+// Reload re-reads the file and applies the changed reloadable settings,
+// all of them or none if one is invalid. Changes of other settings are
+// reported but wait for a restart; settings given on the command line
+// never change.
+func (c *Config) Reload() (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" {
+		return Result{}, errors.New("no configuration file to reload, start with -config")
+	}
+	settings, err := c.read()
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Applied: []Change{}, RestartRequired: []Change{}}
+	var applies []func()
+	names := make([]string, 0, len(c.current))
+	for name := range c.current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.cmdline[name] {
+			continue
+		}
+		f := c.fs.Lookup(name)
+		value, ok := settings[name]
+		if !ok {
+			value = f.DefValue
+		}
+		normalized, err := normalize(f, value)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %s: %w", c.path, name, err)
+		}
+		if normalized == c.current[name] {
+			continue
+		}
+
+		change := Change{Name: name, Old: c.current[name], New: normalized}
+		prepare, ok := c.reloadable[name]
+		if !ok {
+			result.RestartRequired = append(result.RestartRequired, change)
+			continue
+		}
+		apply, err := prepare(value)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %w", name, err)
+		}
+		applies = append(applies, apply)
+		result.Applied = append(result.Applied, change)
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+	for _, change := range result.Applied {
+		c.current[change.Name] = change.New
+	}
+	return result, nil
+}
+
+// normalize returns value as the flag would print it after Set, e.g. "1m0s"
+// for "1m", so that spelling differences do not count as changes. The value
+// is parsed into a fresh copy of the flag value; flags which do not hold a
+// plain pointer to a scalar, whose Set may have side effects, compare as
+// written.
+func normalize(f *flag.Flag, value string) (string, error) {
+	v := reflect.ValueOf(f.Value)
+	if v.Kind() != reflect.Pointer {
+		return value, nil
+	}
+	switch v.Elem().Kind() {
+	case reflect.Bool, reflect.String, reflect.Float64,
+		reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+	default:
+		return value, nil
+	}
+
+	fresh, ok := reflect.New(v.Type().Elem()).Interface().(flag.Value)
+	if !ok {
+		return value, nil
+	}
+	if err := fresh.Set(value); err != nil {
+		return "", err
+	}
+	return fresh.String(), nil
+}
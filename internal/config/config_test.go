@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flags struct {
+	fs       *flag.FlagSet
+	level    *string
+	timeout  *time.Duration
+	workers  *int
+	external *bool
+}
+
+func newFlags(t *testing.T, args ...string) flags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := flags{
+		fs:       fs,
+		level:    fs.String("log-level", "info", ""),
+		timeout:  fs.Duration("shutdown-timeout", 15*time.Second, ""),
+		workers:  fs.Int("workers", 4, ""),
+		external: fs.Bool("external", false, ""),
+	}
+	fs.String("config", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func writeConfig(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortener.conf")
+	writeConfig(t, path, `
+# comment
+log-level = debug
+  shutdown-timeout=1m
+workers = 8
+`)
+	f := newFlags(t, "-workers", "2")
+	if _, err := Load(f.fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if *f.level != "debug" || *f.timeout != time.Minute {
+		t.Errorf("file settings: log-level %q, shutdown-timeout %v", *f.level, *f.timeout)
+	}
+	if *f.workers != 2 {
+		t.Errorf("workers = %d, the command line wins", *f.workers)
+	}
+
+	f = newFlags(t)
+	if _, err := Load(f.fs, ""); err != nil || *f.level != "info" {
+		t.Errorf("without a file: log-level %q, %v", *f.level, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"unknown setting", "color = red\n", `:1: unknown setting "color"`},
+		{"no value", "\nlog-level\n", ":2: expected name = value"},
+		{"no name", "= debug\n", ":1: expected name = value"},
+		{"config", "config = other.conf\n", ":1: config cannot be set in the file"},
+		{"invalid value", "workers = many\n", "workers: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shortener.conf")
+			writeConfig(t, path, tt.text)
+			_, err := Load(newFlags(t).fs, path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(newFlags(t).fs, filepath.Join(t.TempDir(), "missing.conf")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortener.conf")
+	writeConfig(t, path, "log-level = info\nshutdown-timeout = 1m\nexternal = true\n")
+	f := newFlags(t, "-workers", "2")
+	c, err := Load(f.fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := *f.level
+	c.Reloadable("log-level", func(value string) (func(), error) {
+		if value != "debug" && value != "info" {
+			return nil, errors.New("unknown level")
+		}
+		return func() { level = value }, nil
+	})
+
+	// the same values spelled differently are no changes
+	writeConfig(t, path, "log-level = info\nshutdown-timeout = 60s\nexternal = 1\n")
+	result, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 0 || len(result.RestartRequired) != 0 {
+		t.Errorf("unchanged file: %+v", result)
+	}
+
+	// external is back to its default, workers is from the command line
+	writeConfig(t, path, "log-level = debug\nshutdown-timeout = 2m\nworkers = 16\n")
+	result, err = c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 1 || result.Applied[0] != (Change{Name: "log-level", Old: "info", New: "debug"}) || level != "debug" {
+		t.Errorf("applied %+v, level %q", result.Applied, level)
+	}
+	want := []Change{
+		{Name: "external", Old: "true", New: "false"},
+		{Name: "shutdown-timeout", Old: "1m0s", New: "2m0s"},
+	}
+	if len(result.RestartRequired) != len(want) || result.RestartRequired[0] != want[0] || result.RestartRequired[1] != want[1] {
+		t.Errorf("restart required %+v, want %+v", result.RestartRequired, want)
+	}
+
+	// an invalid reloadable value applies nothing
+	writeConfig(t, path, "log-level = loud\n")
+	if _, err := c.Reload(); err == nil || level != "debug" {
+		t.Errorf("invalid level: err = %v, level %q", err, level)
+	}
+	writeConfig(t, path, "log-level = info\nshutdown-timeout = soon\n")
+	if _, err := c.Reload(); err == nil || level != "debug" {
+		t.Errorf("invalid duration: err = %v, level %q", err, level)
+	}
+	writeConfig(t, path, "colour = red\n")
+	if _, err := c.Reload(); err == nil || !strings.Contains(err.Error(), "unknown setting") {
+		t.Errorf("unknown setting: err = %v", err)
+	}
+}
+
+func TestReloadWithoutFile(t *testing.T) {
+	c, err := Load(newFlags(t).fs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Reload(); err == nil {
+		t.Error("Reload without a file succeeded")
+	}
+}
@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+// GatewayPrefix is the path prefix of the HTTP bindings in shortener.proto.
+const GatewayPrefix = "/v0/"
+
+type GatewayOptions struct {
+	// Limit of request bodies, unlimited if zero
+	MaxBodyBytes int64
+}
+
+// NewGateway returns a handler serving the HTTP bindings of the gRPC API
+// as JSON. Calls are forwarded over conn to the gRPC server, so they pass
+// the same authentication, rate limiting and error mapping as native gRPC
+// calls. The gRPC server sees the HTTP client address in x-forwarded-for
+// and must trust the gateway's address to limit per client.
+func NewGateway(conn *grpc.ClientConn, opts GatewayOptions) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+		}),
+		runtime.WithMetadata(gatewayMetadata),
+		runtime.WithOutgoingHeaderMatcher(gatewayResponseHeader),
+	)
+	// the handlers only use ctx for dialing, conn is already established
+	if err := shortener_v0.RegisterShortenerV0Handler(context.Background(), mux, conn); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxBodyBytes <= 0 {
+		return mux, nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes)
+		mux.ServeHTTP(w, r)
+	}), nil
+}
+
+// gatewayMetadata passes the request ID of the HTTP request on to the
+// gRPC call.
+func gatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	if id := logging.RequestID(ctx); id != "" {
+		return metadata.Pairs(logging.RequestIDMetadataKey, id)
+	}
+	return nil
+}
+
+// gatewayResponseHeader maps response metadata to HTTP headers. The
+// request ID is already set by the HTTP server, the content type is the
+// gRPC one.
+func gatewayResponseHeader(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case "retry-after":
+		return "Retry-After", true
+	case logging.RequestIDMetadataKey, "content-type":
+		return "", false
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
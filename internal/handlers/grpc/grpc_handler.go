@@ -3,22 +3,69 @@ package grpc
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"strconv"
 
 	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/qrcode"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/realip"
 	"github.com/vadyaov/url_shortener/internal/service"
 	"github.com/vadyaov/url_shortener/internal/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+type Options struct {
+	// Limits password attempts for protected links per client and link.
+	// Nil means unlimited.
+	PasswordAttempts ratelimit.Limiter
+	// Resolves client addresses for PasswordAttempts
+	ClientIPs *realip.Resolver
+}
+
+// Reasons sent as errdetails.ErrorInfo with PermissionDenied statuses so
+// that clients can tell password errors from ownership errors.
+const (
+	ErrorInfoDomain        = "shortener"
+	ReasonPasswordRequired = "PASSWORD_REQUIRED"
+	ReasonInvalidPassword  = "INVALID_PASSWORD"
+)
+
+func reasonError(code codes.Code, msg, reason string) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: ErrorInfoDomain})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
 type Server struct {
 	shortener_v0.UnimplementedShortenerV0Server
 	service service.URLShortenerService
+	opts    Options
+	log     *slog.Logger
+}
+
+// FullMethod returns the full gRPC method name of a ShortenerV0 method,
+// as seen by interceptors in grpc.UnaryServerInfo.
+func FullMethod(method string) string {
+	return "/" + shortener_v0.ShortenerV0_ServiceDesc.ServiceName + "/" + method
 }
 
-func NewServer(svc service.URLShortenerService) *Server {
+func NewServer(svc service.URLShortenerService, opts Options, logger *slog.Logger) *Server {
+	if opts.ClientIPs == nil {
+		opts.ClientIPs = &realip.Resolver{}
+	}
 	return &Server{
 		service: svc,
+		opts:    opts,
+		log:     logger,
 	}
 }
 
@@ -27,11 +74,29 @@ func (s *Server) GetShortUrl(ctx context.Context, req *shortener_v0.Request) (*s
 		return nil, status.Error(codes.InvalidArgument, "URL is required")
 	}
 
-	short, err := s.service.GetShortUrl(req.GetUrl())
+	opts := service.CreateOptions{
+		Domain:       req.GetDomain(),
+		Password:     req.GetPassword(),
+		Interstitial: req.GetInterstitial(),
+		Metadata: storage.Metadata{
+			Title:       req.GetTitle(),
+			Description: req.GetDescription(),
+			Tags:        req.GetTags(),
+		},
+	}
+	short, err := s.service.GetShortUrl(ctx, req.GetUrl(), opts)
 	if err != nil {
+		if errors.Is(err, service.ErrUnknownDomain) {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown domain '%s'", req.GetDomain())
+		}
+		if errors.Is(err, service.ErrPasswordTooLong) || errors.Is(err, service.ErrInvalidMetadata) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		if errors.Is(err, storage.ErrDuplicateShortCode) {
+			s.log.WarnContext(ctx, "short url conflict", slog.Any("error", err))
 			return nil, status.Error(codes.AlreadyExists, "Failed to create short URL due to conflict")
 		}
+		s.log.ErrorContext(ctx, "failed to create short url", slog.Any("error", err))
 		return nil, status.Error(codes.Internal, "Failed to create short URL")
 	}
 
@@ -43,14 +108,240 @@ func (s *Server) GetOriginUrl(ctx context.Context, req *shortener_v0.Request) (*
 		return nil, status.Error(codes.InvalidArgument, "URL is required")
 	}
 
-	orig, err := s.service.GetOriginUrl(req.GetUrl())
+	info, err := s.service.GetLinkInfo(ctx, req.GetDomain(), req.GetUrl())
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, service.ErrUnknownDomain) {
 			return nil, status.Error(codes.NotFound, "Short Url not found.")
+		} else if errors.Is(err, service.ErrPasswordRequired) {
+			return nil, reasonError(codes.PermissionDenied, "Short Url is password protected, use ResolveUrl.", ReasonPasswordRequired)
 		} else {
+			s.log.ErrorContext(ctx, "failed to get original url", slog.Any("error", err))
 			return nil, status.Error(codes.Internal, "Failed to get original URL")
 		}
 	}
 
-	return &shortener_v0.Response{Url: orig}, nil
+	return metadataResponse(info), nil
+}
+
+func (s *Server) ResolveUrl(ctx context.Context, req *shortener_v0.Request) (*shortener_v0.Response, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "URL is required")
+	}
+
+	info, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return metadataResponse(info), nil
+}
+
+func (s *Server) GetLink(ctx context.Context, req *shortener_v0.Request) (*shortener_v0.Link, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "URL is required")
+	}
+
+	info, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return linkMessage(info), nil
+}
+
+// resolve looks up a link checking its password with limited attempts.
+// Errors are gRPC statuses.
+func (s *Server) resolve(ctx context.Context, req *shortener_v0.Request) (service.LinkInfo, error) {
+	if s.opts.PasswordAttempts != nil && req.GetPassword() != "" {
+		key := ratelimit.ClientKey(ctx, ClientIP(ctx, s.opts.ClientIPs)) + "|" + req.GetDomain() + "/" + req.GetUrl()
+		allowed, retryAfter, err := s.opts.PasswordAttempts.Allow(ctx, key)
+		if err != nil {
+			s.log.WarnContext(ctx, "password attempt limiter failed, allowing request", slog.Any("error", err))
+		} else if !allowed {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter))))
+			return service.LinkInfo{}, status.Error(codes.ResourceExhausted, "Too many password attempts, try again later")
+		}
+	}
+
+	info, err := s.service.ResolveWithPassword(ctx, req.GetDomain(), req.GetUrl(), req.GetPassword())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			return info, status.Error(codes.NotFound, "Short Url not found.")
+		case errors.Is(err, service.ErrPasswordRequired):
+			return info, reasonError(codes.PermissionDenied, "Password required", ReasonPasswordRequired)
+		case errors.Is(err, service.ErrInvalidPassword):
+			return info, reasonError(codes.PermissionDenied, "Wrong password", ReasonInvalidPassword)
+		default:
+			s.log.ErrorContext(ctx, "failed to resolve url", slog.Any("error", err))
+			return info, status.Error(codes.Internal, "Failed to get original URL")
+		}
+	}
+	return info, nil
+}
+
+func (s *Server) DeleteShortUrl(ctx context.Context, req *shortener_v0.Request) (*shortener_v0.Response, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "URL is required")
+	}
+
+	err := s.service.DeleteShortUrl(ctx, req.GetDomain(), req.GetUrl())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			return nil, status.Error(codes.NotFound, "Short Url not found.")
+		case errors.Is(err, auth.ErrUnauthenticated):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, auth.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, "Only the owner can delete this short URL")
+		default:
+			s.log.ErrorContext(ctx, "failed to delete short url", slog.Any("error", err))
+			return nil, status.Error(codes.Internal, "Failed to delete short URL")
+		}
+	}
+
+	return &shortener_v0.Response{Url: req.GetUrl()}, nil
+}
+
+func (s *Server) GetQrCode(ctx context.Context, req *shortener_v0.QrCodeRequest) (*shortener_v0.QrCodeResponse, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "URL is required")
+	}
+
+	opts, err := qrOptionsFromRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	img, err := s.service.GetQrCode(ctx, req.GetDomain(), req.GetUrl(), opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			return nil, status.Error(codes.NotFound, "Short Url not found.")
+		case errors.Is(err, qrcode.ErrInvalidOptions):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			s.log.ErrorContext(ctx, "failed to render QR code", slog.Any("error", err))
+			return nil, status.Error(codes.Internal, "Failed to render QR code")
+		}
+	}
+
+	return &shortener_v0.QrCodeResponse{Image: img, ContentType: opts.ContentType()}, nil
+}
+
+func (s *Server) ListLinks(ctx context.Context, req *shortener_v0.ListLinksRequest) (*shortener_v0.ListLinksResponse, error) {
+	page, err := s.service.ListLinks(ctx, service.ListOptions{
+		ShortDomain: req.GetShortDomain(),
+		Owner:       req.GetOwner(),
+		Tag:         req.GetTag(),
+		Sort:        req.GetSort(),
+		Limit:       int(req.GetLimit()),
+		Cursor:      req.GetCursor(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidListOptions):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, auth.ErrUnauthenticated):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, auth.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, "Only admin API keys can list links of other owners")
+		}
+		s.log.ErrorContext(ctx, "failed to list links", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "Failed to list links")
+	}
+
+	resp := &shortener_v0.ListLinksResponse{NextCursor: page.NextCursor}
+	for _, info := range page.Links {
+		resp.Links = append(resp.Links, linkMessage(info))
+	}
+	return resp, nil
+}
+
+func (s *Server) UpdateLink(ctx context.Context, req *shortener_v0.UpdateLinkRequest) (*shortener_v0.Link, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "URL is required")
+	}
+
+	update := service.LinkUpdate{Title: req.Title, Description: req.Description}
+	if req.GetTags() != nil {
+		tags := req.GetTags().GetValues()
+		update.Tags = &tags
+	}
+
+	info, err := s.service.UpdateLink(ctx, req.GetDomain(), req.GetUrl(), update)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			return nil, status.Error(codes.NotFound, "Short Url not found.")
+		case errors.Is(err, service.ErrInvalidMetadata):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, auth.ErrUnauthenticated):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, auth.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, "Only the owner can update this short URL")
+		default:
+			s.log.ErrorContext(ctx, "failed to update short url", slog.Any("error", err))
+			return nil, status.Error(codes.Internal, "Failed to update short URL")
+		}
+	}
+
+	return linkMessage(info), nil
+}
+
+func metadataResponse(info service.LinkInfo) *shortener_v0.Response {
+	return &shortener_v0.Response{
+		Url:         info.OriginURL,
+		Title:       info.Title,
+		Description: info.Description,
+		Tags:        info.Tags,
+	}
+}
+
+func linkMessage(info service.LinkInfo) *shortener_v0.Link {
+	return &shortener_v0.Link{
+		Domain:       info.Domain,
+		ShortCode:    info.ShortCode,
+		ShortUrl:     info.ShortURL,
+		OriginUrl:    info.OriginURL,
+		Title:        info.Title,
+		Description:  info.Description,
+		Owner:        info.Owner,
+		CreatedAt:    timestamppb.New(info.CreatedAt),
+		Clicks:       info.Clicks,
+		Tags:         info.Tags,
+		Protected:    info.Protected,
+		Interstitial: info.Interstitial,
+	}
+}
+
+func qrOptionsFromRequest(req *shortener_v0.QrCodeRequest) (qrcode.Options, error) {
+	opts := qrcode.DefaultOptions()
+
+	if req.GetFormat() != "" {
+		opts.Format = req.GetFormat()
+	}
+	if req.GetErrorCorrection() != "" {
+		opts.Level = req.GetErrorCorrection()
+	}
+	if req.GetSize() != 0 {
+		opts.Size = int(req.GetSize())
+	}
+	if req.Margin != nil {
+		opts.Margin = int(req.GetMargin())
+	}
+	if req.GetForeground() != "" {
+		c, err := qrcode.ParseColor(req.GetForeground())
+		if err != nil {
+			return opts, err
+		}
+		opts.Foreground = c
+	}
+	if req.GetBackground() != "" {
+		c, err := qrcode.ParseColor(req.GetBackground())
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = c
+	}
+
+	return opts, opts.Validate()
 }
@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+	"github.com/vadyaov/url_shortener/internal/health"
+)
+
+// WatchHealth periodically runs the readiness checks and publishes the result
+// through the standard grpc.health.v1 service until ctx is done.
+func WatchHealth(ctx context.Context, checker *health.Checker, hs *grpchealth.Server, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := checker.Ready(checkCtx)
+		cancel()
+
+		st := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if st != last {
+			if err != nil {
+				logger.Warn("gRPC health status changed", slog.String("status", st.String()), slog.Any("reason", err))
+			} else {
+				logger.Info("gRPC health status changed", slog.String("status", st.String()))
+			}
+			last = st
+		}
+		hs.SetServingStatus("", st)
+		hs.SetServingStatus(shortener_v0.ShortenerV0_ServiceDesc.ServiceName, st)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/realip"
+)
+
+// InterceptorOptions configures the interceptor chain built by
+// Interceptors. Nil fields leave their interceptor out.
+type InterceptorOptions struct {
+	// Logs every call with its status code and duration
+	CallLogger *slog.Logger
+	Metrics    *Metrics
+	// Requires a verified TLS client certificate for these methods, e.g.
+	// administrative services
+	ClientCertMethods map[string]bool
+	// Requires API keys for AuthMethods
+	Authn       *auth.Authenticator
+	AuthMethods map[string]bool
+	// Limiters by full method name, keyed by client address from ClientIPs
+	Limiters  map[string]ratelimit.Limiter
+	ClientIPs *realip.Resolver
+}
+
+// Interceptors returns server options chaining, for unary and streaming
+// calls alike: panic recovery, request IDs, call logging, metrics, client
+// certificate checks, authentication and rate limiting. Recovery comes first so that panics
+// anywhere in the chain become codes.Internal; authentication comes
+// before rate limiting so that limits count per API key.
+func Interceptors(opts InterceptorOptions, logger *slog.Logger) []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{RecoveryUnaryInterceptor(logger), RequestIDUnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{RecoveryStreamInterceptor(logger), RequestIDStreamInterceptor}
+	if opts.CallLogger != nil {
+		unary = append(unary, LoggingUnaryInterceptor(opts.CallLogger))
+		stream = append(stream, LoggingStreamInterceptor(opts.CallLogger))
+	}
+	if opts.Metrics != nil {
+		unary = append(unary, MetricsUnaryInterceptor(opts.Metrics))
+		stream = append(stream, MetricsStreamInterceptor(opts.Metrics))
+	}
+	if len(opts.ClientCertMethods) > 0 {
+		unary = append(unary, ClientCertUnaryInterceptor(opts.ClientCertMethods))
+		stream = append(stream, ClientCertStreamInterceptor(opts.ClientCertMethods))
+	}
+	if opts.Authn != nil {
+		unary = append(unary, AuthUnaryInterceptor(opts.Authn, opts.AuthMethods, logger))
+		stream = append(stream, AuthStreamInterceptor(opts.Authn, opts.AuthMethods, logger))
+	}
+	if len(opts.Limiters) > 0 {
+		ips := opts.ClientIPs
+		if ips == nil {
+			ips = &realip.Resolver{}
+		}
+		unary = append(unary, RateLimitUnaryInterceptor(opts.Limiters, ips, logger))
+		stream = append(stream, RateLimitStreamInterceptor(opts.Limiters, ips, logger))
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+func withStreamContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	if ctx == ss.Context() {
+		return ss
+	}
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
+
+// RecoveryUnaryInterceptor turns panics of handlers into codes.Internal
+// and logs them with the stack.
+func RecoveryUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, logger, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func RecoveryStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), logger, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, logger *slog.Logger, method string, p any) error {
+	logger.ErrorContext(ctx, "panic in gRPC handler",
+		slog.String("method", method),
+		slog.Any("panic", p),
+		slog.String("stack", string(debug.Stack())))
+	return status.Error(codes.Internal, "Internal error")
+}
+
+// RequestIDUnaryInterceptor takes the request ID from the x-request-id
+// metadata or generates a new one, stores it in the context and sends it
+// back in the response header.
+func RequestIDUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+func RequestIDStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, withStreamContext(ss, withRequestID(ss.Context())))
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(logging.RequestIDMetadataKey); len(vals) > 0 {
+			id = logging.SanitizeRequestID(vals[0])
+		}
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDMetadataKey, id))
+	return logging.WithRequestID(ctx, id)
+}
+
+// LoggingUnaryInterceptor logs every call with its status code and
+// duration. Server errors are logged at error level, the rest at info.
+func LoggingUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, err, time.Since(start))
+		return resp, err
+	}
+}
+
+func LoggingStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), logger, info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, err error, elapsed time.Duration) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", elapsed),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	logger.LogAttrs(ctx, level, "gRPC call", attrs...)
+}
+
+// MetricsUnaryInterceptor records every call in m.
+func MetricsUnaryInterceptor(m *Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.Observe(info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+func MetricsStreamInterceptor(m *Metrics) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.Observe(info.FullMethod, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// AuthUnaryInterceptor requires a valid API key in the "authorization"
+// metadata ("Bearer <key>") for the listed methods and stores the key in the
+// context for ownership checks and per-key rate limiting.
+func AuthUnaryInterceptor(authn *auth.Authenticator, methods map[string]bool, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, authn, logger)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthStreamInterceptor(authn *auth.Authenticator, methods map[string]bool, logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !methods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), authn, logger)
+		if err != nil {
+			return err
+		}
+		return handler(srv, withStreamContext(ss, ctx))
+	}
+}
+
+// ReflectionMethods stands for both versions of the server reflection
+// service in ParseMethods.
+const ReflectionMethods = "reflection"
+
+// ParseMethods maps ShortenerV0 method names such as "ListLinks", and
+// ReflectionMethods, to the full method names seen by interceptors.
+func ParseMethods(names []string) (map[string]bool, error) {
+	methods := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == ReflectionMethods:
+			methods[reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName] = true
+			methods[reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName] = true
+		case slices.ContainsFunc(shortener_v0.ShortenerV0_ServiceDesc.Methods, func(m grpc.MethodDesc) bool { return m.MethodName == name }):
+			methods[FullMethod(name)] = true
+		default:
+			return nil, fmt.Errorf("unknown gRPC method %q", name)
+		}
+	}
+	return methods, nil
+}
+
+// ClientCertUnaryInterceptor fails calls of methods without a client
+// certificate verified by the TLS handshake with codes.Unauthenticated.
+func ClientCertUnaryInterceptor(methods map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if methods[info.FullMethod] && !hasVerifiedClientCert(ctx) {
+			return nil, status.Error(codes.Unauthenticated, "Client certificate required")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func ClientCertStreamInterceptor(methods map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if methods[info.FullMethod] && !hasVerifiedClientCert(ss.Context()) {
+			return status.Error(codes.Unauthenticated, "Client certificate required")
+		}
+		return handler(srv, ss)
+	}
+}
+
+func hasVerifiedClientCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+func authenticate(ctx context.Context, authn *auth.Authenticator, logger *slog.Logger) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get("authorization"); len(vals) > 0 {
+			token = auth.TokenFromAuthorization(vals[0])
+		}
+	}
+
+	key, err := authn.Authenticate(token)
+	if err != nil {
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		logger.ErrorContext(ctx, "failed to authenticate API key", slog.Any("error", err))
+		return ctx, status.Error(codes.Internal, "Failed to authenticate")
+	}
+
+	ctx = auth.WithKey(ctx, key)
+	ctx = ratelimit.WithClientID(ctx, key.ID)
+	return ctx, nil
+}
+
+// RateLimitUnaryInterceptor applies the limiter registered for the called
+// method (keyed by full method name) and fails calls over the budget with
+// codes.ResourceExhausted. Methods without a limiter are not limited.
+func RateLimitUnaryInterceptor(limiters map[string]ratelimit.Limiter, ips *realip.Resolver, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := allow(ctx, limiters, info.FullMethod, ips, logger); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor limits the start of streams like
+// RateLimitUnaryInterceptor limits calls.
+func RateLimitStreamInterceptor(limiters map[string]ratelimit.Limiter, ips *realip.Resolver, logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), limiters, info.FullMethod, ips, logger); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func allow(ctx context.Context, limiters map[string]ratelimit.Limiter, method string, ips *realip.Resolver, logger *slog.Logger) error {
+	limiter, ok := limiters[method]
+	if !ok {
+		return nil
+	}
+
+	key := ratelimit.ClientKey(ctx, ClientIP(ctx, ips))
+	allowed, retryAfter, err := limiter.Allow(ctx, key)
+	if err != nil {
+		logger.WarnContext(ctx, "rate limiter failed, allowing request", slog.Any("error", err))
+		return nil
+	}
+	if !allowed {
+		logger.DebugContext(ctx, "rate limit exceeded", slog.String("client", key), slog.String("method", method))
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter))))
+		return status.Error(codes.ResourceExhausted, "Rate limit exceeded, try again later")
+	}
+	return nil
+}
+
+// ClientIP returns the address of the calling client, honouring forwarding
+// metadata set by trusted proxies.
+func ClientIP(ctx context.Context, ips *realip.Resolver) string {
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var realIP string
+	if vals := md.Get("x-real-ip"); len(vals) > 0 {
+		realIP = vals[0]
+	}
+	return ips.Resolve(remote, md.Get("x-forwarded-for"), realIP)
+}
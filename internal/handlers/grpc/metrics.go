@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Metrics counts gRPC calls per method and status code and tracks their
+// latency. It is served as JSON.
+type Metrics struct {
+	mu      sync.Mutex
+	started time.Time
+	methods map[string]*methodStats
+}
+
+type methodStats struct {
+	Calls        int64            `json:"calls"`
+	Codes        map[string]int64 `json:"codes"`
+	TotalSeconds float64          `json:"total_seconds"`
+	MaxSeconds   float64          `json:"max_seconds"`
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{started: time.Now(), methods: map[string]*methodStats{}}
+}
+
+// Observe records a finished call.
+func (m *Metrics) Observe(method string, code codes.Code, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.methods[method]
+	if !ok {
+		stats = &methodStats{Codes: map[string]int64{}}
+		m.methods[method] = stats
+	}
+	stats.Calls++
+	stats.Codes[code.String()]++
+	seconds := elapsed.Seconds()
+	stats.TotalSeconds += seconds
+	stats.MaxSeconds = max(stats.MaxSeconds, seconds)
+}
+
+// String returns the metrics as JSON, which also makes Metrics an
+// expvar.Var.
+func (m *Metrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, _ := json.Marshal(struct {
+		UptimeSeconds float64                 `json:"uptime_seconds"`
+		Methods       map[string]*methodStats `json:"methods"`
+	}{time.Since(m.started).Seconds(), m.methods})
+	return string(data)
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(m.String()))
+}
@@ -0,0 +1,35 @@
+package grpc
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// Multiplex serves gRPC requests with server and everything else with
+// next, for gRPC and HTTP on one listener. The HTTP server must speak
+// HTTP/2, over TLS or as h2c.
+//
+// Calls go through grpc.Server.ServeHTTP, which uses the HTTP/2
+// implementation of net/http: the interceptors and credentials of server
+// apply, its transport settings like keepalive and stream limits do not.
+func Multiplex(server *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPCRequest(r) {
+			server.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsGRPCRequest reports whether r is a gRPC call, as opposed to gRPC-Web or
+// plain HTTP.
+func IsGRPCRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost {
+		return false
+	}
+	ct := r.Header.Get("Content-Type")
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+") || strings.HasPrefix(ct, "application/grpc;")
+}
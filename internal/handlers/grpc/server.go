@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ServerSettings are transport settings of the gRPC server. Zero values
+// keep the grpc defaults.
+type ServerSettings struct {
+	// Idle time after which the server pings a connection, and how long it
+	// waits for the ping ack before closing the connection
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	// Largest message the server receives or sends
+	MaxMsgSize int
+	// Concurrent streams (calls) per connection
+	MaxConcurrentStreams uint32
+}
+
+func (s ServerSettings) ServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if s.KeepaliveTime > 0 || s.KeepaliveTimeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    s.KeepaliveTime,
+			Timeout: s.KeepaliveTimeout,
+		}))
+	}
+	if s.MaxMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(s.MaxMsgSize), grpc.MaxSendMsgSize(s.MaxMsgSize))
+	}
+	if s.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(s.MaxConcurrentStreams))
+	}
+	return opts
+}
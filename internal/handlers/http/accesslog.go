@@ -0,0 +1,136 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/realip"
+)
+
+// Access log formats.
+const (
+	// One structured record per request through the application logger
+	AccessLogStructured = "structured"
+	// Apache combined log format lines, followed by the latency in
+	// microseconds
+	AccessLogCombined = "combined"
+	AccessLogOff      = "off"
+)
+
+// AccessLog logs every request with its status, response size and
+// latency. Query strings are left out, they may carry secrets.
+func AccessLog(format string, logger *slog.Logger, out io.Writer) (Middleware, error) {
+	var write func(r *http.Request, rec *statusRecorder, start time.Time, elapsed time.Duration)
+	switch format {
+	case AccessLogOff:
+		return func(next http.Handler) http.Handler { return next }, nil
+	case AccessLogStructured:
+		write = func(r *http.Request, rec *statusRecorder, start time.Time, elapsed time.Duration) {
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "HTTP request",
+				slog.String("method", r.Method),
+				slog.String("host", r.Host),
+				slog.String("path", r.URL.Path),
+				slog.String("proto", r.Proto),
+				slog.Int("status", rec.status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("duration", elapsed),
+				slog.String("client", clientIP(r)),
+				slog.String("user_agent", r.UserAgent()))
+		}
+	case AccessLogCombined:
+		var mu sync.Mutex
+		write = func(r *http.Request, rec *statusRecorder, start time.Time, elapsed time.Duration) {
+			line := fmt.Sprintf("%s - - [%s] %q %d %d %q %q %d\n",
+				clientIP(r),
+				start.Format("02/Jan/2006:15:04:05 -0700"),
+				r.Method+" "+r.URL.Path+" "+r.Proto,
+				rec.status,
+				rec.bytes,
+				orDash(r.Referer()),
+				orDash(r.UserAgent()),
+				elapsed.Microseconds())
+			mu.Lock()
+			defer mu.Unlock()
+			io.WriteString(out, line)
+		}
+	default:
+		return nil, fmt.Errorf("unknown access log format %q, use %q, %q or %q", format, AccessLogStructured, AccessLogCombined, AccessLogOff)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				if rec.status == 0 {
+					rec.status = http.StatusOK
+				}
+				write(r, rec, start, time.Since(start))
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}, nil
+}
+
+func clientIP(r *http.Request) string {
+	if ip, ok := realip.ClientIP(r.Context()); ok {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// statusRecorder remembers the status code and counts the bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,207 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// APIPrefix is the path prefix of the versioned REST API.
+const APIPrefix = "/api/v1"
+
+// Route is an endpoint of the REST API. The server wraps Handler in the
+// rate limit of Class, after API key authentication if Auth is set.
+type Route struct {
+	Pattern string
+	Handler http.HandlerFunc
+	Class   ratelimit.Class
+	Auth    bool
+}
+
+// LinkListResponse is a page of links. Status is only set by the legacy
+// GET /links endpoint, whose responses all carry it.
+type LinkListResponse struct {
+	Links      []LinkResponse `json:"links"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Status     int            `json:"status,omitempty"`
+}
+
+func linkListResponse(page service.LinkPage) LinkListResponse {
+	resp := LinkListResponse{Links: make([]LinkResponse, 0, len(page.Links)), NextCursor: page.NextCursor}
+	for _, info := range page.Links {
+		resp.Links = append(resp.Links, linkResponse(info))
+	}
+	return resp
+}
+
+// V1Routes returns the endpoints of the REST API, all of which are
+// described by the OpenAPI document served at APIPrefix+"/openapi.json".
+// Links are addressed by short code; the "domain" query parameter selects
+// a short domain other than the default one.
+func (h *UrlHandler) V1Routes() []Route {
+	return []Route{
+		{Pattern: "GET " + APIPrefix + "/openapi.json", Handler: h.HandleOpenAPI, Class: ratelimit.ClassLookup},
+		{Pattern: "GET " + APIPrefix + "/links", Handler: h.HandleListLinksV1, Class: ratelimit.ClassLookup, Auth: true},
+		{Pattern: "POST " + APIPrefix + "/links", Handler: h.HandleCreateLink, Class: ratelimit.ClassCreate, Auth: true},
+		{Pattern: "GET " + APIPrefix + "/links/{code}", Handler: h.HandleGetLink, Class: ratelimit.ClassLookup},
+		{Pattern: "POST " + APIPrefix + "/links/{code}/resolve", Handler: h.HandleResolveLink, Class: ratelimit.ClassLookup},
+		{Pattern: "PATCH " + APIPrefix + "/links/{code}", Handler: h.HandleUpdateLink, Class: ratelimit.ClassCreate, Auth: true},
+		{Pattern: "DELETE " + APIPrefix + "/links/{code}", Handler: h.HandleDeleteLink, Class: ratelimit.ClassCreate, Auth: true},
+	}
+}
+
+func (h *UrlHandler) HandleListLinksV1(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.listLinks(w, r)
+	if !ok {
+		return
+	}
+
+	resp := linkListResponse(page)
+	respondWithJSON(w, http.StatusOK, &resp)
+}
+
+// HandleCreateLink creates a link from a JSON or form body and answers
+// 201 with the link and its Location.
+func (h *UrlHandler) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if !h.decodeRequest(w, r, &req, req.fromForm) {
+		return
+	}
+	if req.URL == "" {
+		respondWithError(w, http.StatusBadRequest, "Incorrect or empty 'url' field")
+		return
+	}
+
+	code, err := h.service.GetShortUrl(r.Context(), req.URL, service.CreateOptions{
+		Domain:       req.Domain,
+		Password:     req.Password,
+		Interstitial: req.Interstitial,
+		Metadata: storage.Metadata{
+			Title:       req.Title,
+			Description: req.Description,
+			Tags:        req.Tags,
+		},
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownDomain) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown domain '%s'", req.Domain))
+			return
+		}
+		h.respondWithLinkError(w, r, "create", err)
+		return
+	}
+
+	// the password is known here, so protected links can be read back too
+	info, err := h.service.ResolveWithPassword(r.Context(), req.Domain, code, req.Password)
+	if err != nil {
+		h.respondWithLinkError(w, r, "create", err)
+		return
+	}
+
+	w.Header().Set("Location", linkLocation(req.Domain, code))
+	respondWithJSON(w, http.StatusCreated, linkResponse(info))
+}
+
+// HandleGetLink answers with the details of a link. Password protected
+// links are refused with 403, HandleResolveLink takes their password.
+func (h *UrlHandler) HandleGetLink(w http.ResponseWriter, r *http.Request) {
+	info, err := h.service.GetLinkInfo(r.Context(), r.URL.Query().Get("domain"), r.PathValue("code"))
+	if err != nil {
+		h.respondWithLinkError(w, r, "get", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, linkResponse(info))
+}
+
+// HandleResolveLink answers with the details of a link given its password
+// in a JSON or form body. Unlike the password form of the redirect it does
+// not count a click. Password attempts are limited the same way.
+func (h *UrlHandler) HandleResolveLink(w http.ResponseWriter, r *http.Request) {
+	var req resolveRequest
+	if !h.decodeRequest(w, r, &req, req.fromForm) {
+		return
+	}
+
+	domain, code := r.URL.Query().Get("domain"), r.PathValue("code")
+	if req.Password != "" {
+		if retryAfter, ok := h.allowPasswordAttempt(r, domain, code); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "Too many password attempts, try again later")
+			return
+		}
+	}
+
+	info, err := h.service.ResolveWithPassword(r.Context(), domain, code, req.Password)
+	if err != nil {
+		h.respondWithLinkError(w, r, "resolve", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, linkResponse(info))
+}
+
+// HandleUpdateLink changes the metadata fields present in a JSON or form
+// body. Only the owner may update a link.
+func (h *UrlHandler) HandleUpdateLink(w http.ResponseWriter, r *http.Request) {
+	var patch linkPatch
+	if !h.decodeRequest(w, r, &patch, patch.fromForm) {
+		return
+	}
+
+	info, err := h.service.UpdateLink(r.Context(), r.URL.Query().Get("domain"), r.PathValue("code"), patch.update())
+	if err != nil {
+		h.respondWithLinkError(w, r, "update", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, linkResponse(info))
+}
+
+// HandleDeleteLink deletes a link and answers 204. Only the owner may
+// delete a link.
+func (h *UrlHandler) HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteShortUrl(r.Context(), r.URL.Query().Get("domain"), r.PathValue("code")); err != nil {
+		h.respondWithLinkError(w, r, "delete", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithLinkError maps service errors of an action on a single link
+// to responses.
+func (h *UrlHandler) respondWithLinkError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+		respondWithError(w, http.StatusNotFound, "Short URL not found")
+	case errors.Is(err, service.ErrPasswordRequired):
+		respondWithReason(w, http.StatusForbidden, ReasonPasswordRequired, "Short URL is password protected")
+	case errors.Is(err, service.ErrInvalidPassword):
+		respondWithReason(w, http.StatusForbidden, ReasonInvalidPassword, "Wrong password")
+	case errors.Is(err, service.ErrPasswordTooLong), errors.Is(err, service.ErrInvalidMetadata):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrDuplicateShortCode):
+		h.log.WarnContext(r.Context(), "short url conflict", slog.Any("error", err))
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Failed to create short URL due to conflict: %v", err))
+	case errors.Is(err, auth.ErrUnauthenticated):
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("Only the owner can %s this short URL", action))
+	default:
+		h.log.ErrorContext(r.Context(), "failed to "+action+" link", slog.Any("error", err))
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s link", action))
+	}
+}
+
+func linkLocation(domain, code string) string {
+	location := APIPrefix + "/links/" + url.PathEscape(code)
+	if domain != "" {
+		location += "?" + url.Values{"domain": {domain}}.Encode()
+	}
+	return location
+}
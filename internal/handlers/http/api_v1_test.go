@@ -0,0 +1,211 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+func TestHandleResolveLink(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.Discard()
+	store := storage.NewInMemoryStore(logger)
+	clicks := service.NewClickRecorder(store, time.Hour, logger)
+	svc := service.NewUrlService(store, service.NewDomains("https", "sho.rt"), service.Options{Clicks: clicks}, logger)
+	h := NewUrlHandler(svc, Options{
+		PasswordAttempts: ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: 2}),
+	}, logger)
+	mux := http.NewServeMux()
+	for _, route := range h.V1Routes() {
+		mux.Handle(route.Pattern, route.Handler)
+	}
+	mux.HandleFunc("/", h.HandleRedirect)
+
+	code, err := svc.GetShortUrl(ctx, "https://example.com/secret", service.CreateOptions{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := func(body string) (*http.Response, Response) {
+		t.Helper()
+		req := httptest.NewRequest("POST", APIPrefix+"/links/"+code+"/resolve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp Response
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Result(), resp
+	}
+	clickCount := func() int64 {
+		t.Helper()
+		clicks.Flush()
+		info, err := svc.ResolveWithPassword(ctx, "", code, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Clicks
+	}
+
+	if resp, body := resolve(`{}`); resp.StatusCode != http.StatusForbidden || body.Reason != ReasonPasswordRequired {
+		t.Errorf("without password: status %d, reason %q", resp.StatusCode, body.Reason)
+	}
+
+	resp, _ := resolve(`{"password":"secret"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("with password: status %d", resp.StatusCode)
+	}
+	var link LinkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil || link.OriginURL != "https://example.com/secret" {
+		t.Errorf("with password: link %+v, %v", link, err)
+	}
+	if n := clickCount(); n != 0 {
+		t.Errorf("clicks after resolve = %d, want 0", n)
+	}
+
+	// the password form of the redirect counts the visit
+	req := httptest.NewRequest("POST", "/"+code, strings.NewReader("password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Host = "sho.rt"
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("password form: status %d", rec.Code)
+	}
+	if n := clickCount(); n != 1 {
+		t.Errorf("clicks after password form = %d, want 1", n)
+	}
+
+	if resp, body := resolve(`{"password":"wrong"}`); resp.StatusCode != http.StatusForbidden || body.Reason != ReasonInvalidPassword {
+		t.Errorf("wrong password: status %d, reason %q", resp.StatusCode, body.Reason)
+	}
+	// both attempts of the client are used up
+	if resp, body := resolve(`{"password":"wrong"}`); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("over the limit: status %d, Retry-After %q: %s", resp.StatusCode, resp.Header.Get("Retry-After"), body.Error)
+	}
+}
+
+func TestListLinksOwners(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.Discard()
+	authn := auth.NewAuthenticator(auth.NewInMemoryKeyStore())
+	svc := service.NewUrlService(storage.NewInMemoryStore(logger), service.NewDomains("https", "sho.rt"), service.Options{}, logger)
+	h := NewUrlHandler(svc, Options{}, logger)
+	mux := http.NewServeMux()
+	for _, route := range h.V1Routes() {
+		var handler http.Handler = route.Handler
+		if route.Auth {
+			handler = RequireAPIKey(authn, logger)(handler)
+		}
+		mux.Handle(route.Pattern, handler)
+	}
+
+	keys := map[string]string{}
+	ids := map[string]string{}
+	for _, name := range []string{"alice", "bob", "admin"} {
+		key, token, err := authn.CreateKey(name, name == "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[name], ids[name] = token, key.ID
+	}
+	for i, name := range []string{"alice", "alice", "bob"} {
+		key, _ := authn.Authenticate(keys[name])
+		if _, err := svc.GetShortUrl(auth.WithKey(ctx, key), "https://example.com/"+strconv.Itoa(i), service.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key, owner string
+		want       int
+		// owners of the links listed
+		owners []string
+	}{
+		{key: "alice", want: 200, owners: []string{"alice", "alice"}},
+		{key: "alice", owner: "alice", want: 200, owners: []string{"alice", "alice"}},
+		{key: "bob", want: 200, owners: []string{"bob"}},
+		{key: "bob", owner: "alice", want: 403},
+		{key: "admin", want: 200, owners: []string{"alice", "alice", "bob"}},
+		{key: "admin", owner: "bob", want: 200, owners: []string{"bob"}},
+	}
+	for _, tt := range tests {
+		path := APIPrefix + "/links"
+		if tt.owner != "" {
+			path += "?owner=" + ids[tt.owner]
+		}
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+keys[tt.key])
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s listing %q: status %d, want %d", tt.key, tt.owner, rec.Code, tt.want)
+			continue
+		}
+		if tt.want != 200 {
+			continue
+		}
+
+		var page LinkListResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		var owners []string
+		for _, link := range page.Links {
+			for name, id := range ids {
+				if link.Owner == id {
+					owners = append(owners, name)
+				}
+			}
+		}
+		slices.Sort(owners)
+		if !slices.Equal(owners, tt.owners) {
+			t.Errorf("%s listing %q: owners %q, want %q", tt.key, tt.owner, owners, tt.owners)
+		}
+	}
+}
+
+func TestListLinksShortDomain(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.Discard()
+	authn := auth.NewAuthenticator(auth.NewInMemoryKeyStore())
+	svc := service.NewUrlService(storage.NewInMemoryStore(logger), service.NewDomains("https", "sho.rt", "go.example"), service.Options{}, logger)
+	h := NewUrlHandler(svc, Options{}, logger)
+	key, token, err := authn.CreateKey("alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = auth.WithKey(ctx, key)
+
+	// the original URL of the first link contains the filter, its short domain does not
+	if _, err := svc.GetShortUrl(ctx, "https://go.example.org/a", service.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	code, err := svc.GetShortUrl(ctx, "https://example.com/b", service.CreateOptions{Domain: "go.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", APIPrefix+"/links?short_domain=GO.", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	RequireAPIKey(authn, logger)(http.HandlerFunc(h.HandleListLinksV1)).ServeHTTP(rec, req)
+	var page LinkListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	if len(page.Links) != 1 || page.Links[0].ShortCode != code {
+		t.Errorf("links = %+v, want only %s on go.example", page.Links, code)
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/health"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthHandler struct {
+	checker *health.Checker
+	log     *slog.Logger
+}
+
+func NewHealthHandler(checker *health.Checker, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		log:     logger,
+	}
+}
+
+// HandleLiveness reports that the process is up and serving requests.
+func (h *HealthHandler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &healthResponse{Status: "ok"})
+}
+
+// HandleReadiness reports whether the instance should receive traffic.
+func (h *HealthHandler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := h.checker.Ready(ctx); err != nil {
+		h.log.DebugContext(ctx, "readiness check failed", slog.Any("error", err))
+		respondWithJSON(w, http.StatusServiceUnavailable, &healthResponse{Status: "unavailable", Error: err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &healthResponse{Status: "ok"})
+}
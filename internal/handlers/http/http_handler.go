@@ -1,117 +1,348 @@
-package http
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"net/http"
-	"strings"
-
-	"github.com/vadyaov/url_shortener/internal/service"
-	"github.com/vadyaov/url_shortener/internal/storage"
-)
-
-type Response struct {
-	Url    string `json:"url"`
-	Status int    `json:"status"`
-	Error  string `json:"error"`
-}
-
-type UrlHandler struct {
-	service service.URLShortenerService
-}
-
-func NewUrlHandler(svc service.URLShortenerService) *UrlHandler {
-	return &UrlHandler{
-		service: svc,
-	}
-}
-
-func (h *UrlHandler) HandleCreateShortUrl(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	if err := r.ParseForm(); err != nil {
-		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing form: %v", err))
-		return
-	}
-
-	origin_url := r.Form.Get("url")
-	if origin_url == "" {
-		respondWithError(w, http.StatusBadRequest, "Incorrect or empry 'url' field")
-		return
-	}
-
-	short, err := h.service.GetShortUrl(origin_url)
-	if err != nil {
-		if errors.Is(err, storage.ErrDuplicateShortCode) {
-			respondWithError(w, http.StatusConflict, fmt.Sprintf("Failed to create short URL due to conflict: %v", err))
-		} else {
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create short URL: %v", err))
-		}
-		return
-	}
-
-	// fullShortUrl := fmt.Sprintf("http://%s/%s", r.Host, short)
-
-	respondWithJSON(w, http.StatusCreated, &Response{Url: short, Status: http.StatusCreated})
-}
-
-func (h *UrlHandler) HandleGetOriginUrl(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	short_url := r.URL.Query().Get("url")
-	if short_url == "" {
-		respondWithError(w, http.StatusBadRequest, "Incorrect or empty 'url' field")
-		return
-	}
-
-	origin, err := h.service.GetOriginUrl(short_url)
-	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			respondWithError(w, http.StatusNotFound, "Short URL not found")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get original URL: %v", err))
-		}
-		return
-	}
-
-	respondWithJSON(w, http.StatusOK, &Response{Url: origin, Status: http.StatusOK})
-}
-
-func (h *UrlHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	shortCode := strings.TrimPrefix(r.URL.Path, "/")
-	if shortCode == "" {
-		http.Error(w, "Short code is missing", http.StatusBadRequest)
-		return
-	}
-
-	originUrl, err := h.service.GetOriginUrl(shortCode)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
-
-	http.Redirect(w, r, originUrl, http.StatusMovedPermanently)
-}
-
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, Response{Error: message, Status: code})
-}
-
-func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, _ := json.Marshal(payload)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
-}
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/realip"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+type Response struct {
+	Url         string   `json:"url,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Status      int      `json:"status"`
+	Error       string   `json:"error"`
+	// Reason of a password error, one of the Reason constants
+	Reason string `json:"reason,omitempty"`
+}
+
+// Values of Response.Reason, the same as the ErrorInfo reasons of the gRPC
+// API.
+const (
+	ReasonPasswordRequired = "PASSWORD_REQUIRED"
+	ReasonInvalidPassword  = "INVALID_PASSWORD"
+)
+
+// Values of Options.UnknownHost besides a fallback URL.
+const (
+	UnknownHostNotFound = "404"
+	UnknownHostDefault  = "default"
+)
+
+type Options struct {
+	// What HandleRedirect does for a Host which is not a configured short
+	// domain: UnknownHostNotFound answers 404, UnknownHostDefault resolves
+	// the code in the default domain, any other value is a URL to redirect to.
+	UnknownHost string
+
+	// Limits password attempts for protected links per client and link.
+	// Nil means unlimited.
+	PasswordAttempts ratelimit.Limiter
+	// Resolves client addresses for PasswordAttempts
+	ClientIPs *realip.Resolver
+
+	// Limit of request bodies, DefaultMaxBodyBytes if zero
+	MaxBodyBytes int64
+}
+
+type UrlHandler struct {
+	service service.URLShortenerService
+	opts    Options
+	log     *slog.Logger
+}
+
+func NewUrlHandler(svc service.URLShortenerService, opts Options, logger *slog.Logger) *UrlHandler {
+	if opts.UnknownHost == "" {
+		opts.UnknownHost = UnknownHostNotFound
+	}
+	if opts.ClientIPs == nil {
+		opts.ClientIPs = &realip.Resolver{}
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &UrlHandler{
+		service: svc,
+		opts:    opts,
+		log:     logger,
+	}
+}
+
+func (h *UrlHandler) HandleCreateShortUrl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req createRequest
+	if !h.decodeRequest(w, r, &req, req.fromForm) {
+		return
+	}
+
+	origin_url := req.URL
+	if origin_url == "" {
+		respondWithError(w, http.StatusBadRequest, "Incorrect or empry 'url' field")
+		return
+	}
+
+	opts := service.CreateOptions{
+		Domain:       req.Domain,
+		Password:     req.Password,
+		Interstitial: req.Interstitial,
+		Metadata: storage.Metadata{
+			Title:       req.Title,
+			Description: req.Description,
+			Tags:        req.Tags,
+		},
+	}
+	short, err := h.service.GetShortUrl(r.Context(), origin_url, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownDomain) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown domain '%s'", opts.Domain))
+		} else if errors.Is(err, service.ErrPasswordTooLong) || errors.Is(err, service.ErrInvalidMetadata) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else if errors.Is(err, storage.ErrDuplicateShortCode) {
+			h.log.WarnContext(r.Context(), "short url conflict", slog.Any("error", err))
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Failed to create short URL due to conflict: %v", err))
+		} else {
+			h.log.ErrorContext(r.Context(), "failed to create short url", slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create short URL: %v", err))
+		}
+		return
+	}
+
+	// fullShortUrl := fmt.Sprintf("http://%s/%s", r.Host, short)
+
+	respondWithJSON(w, http.StatusCreated, &Response{Url: short, Status: http.StatusCreated})
+}
+
+// HandleGetOriginUrl looks up a short code given in the query, or with
+// POST in a JSON or form body.
+func (h *UrlHandler) HandleGetOriginUrl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req lookupRequest
+	if !h.decodeRequest(w, r, &req, req.fromForm) {
+		return
+	}
+
+	short_url := req.URL
+	if short_url == "" {
+		respondWithError(w, http.StatusBadRequest, "Incorrect or empty 'url' field")
+		return
+	}
+
+	info, err := h.service.GetLinkInfo(r.Context(), req.Domain, short_url)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, service.ErrUnknownDomain) {
+			respondWithError(w, http.StatusNotFound, "Short URL not found")
+		} else if errors.Is(err, service.ErrPasswordRequired) {
+			respondWithReason(w, http.StatusForbidden, ReasonPasswordRequired, "Short URL is password protected")
+		} else {
+			h.log.ErrorContext(r.Context(), "failed to get original url", slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get original URL: %v", err))
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &Response{
+		Url:         info.OriginURL,
+		Title:       info.Title,
+		Description: info.Description,
+		Tags:        info.Tags,
+		Status:      http.StatusOK,
+	})
+}
+
+// HandleUpdateShortUrl changes link metadata. Only the fields present in
+// the request (title, description, tags) are changed; an empty "tags"
+// field removes all tags.
+func (h *UrlHandler) HandleUpdateShortUrl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Only PATCH method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req updateRequest
+	if !h.decodeRequest(w, r, &req, req.fromForm) {
+		return
+	}
+
+	short_url := req.URL
+	if short_url == "" {
+		respondWithError(w, http.StatusBadRequest, "Incorrect or empty 'url' field")
+		return
+	}
+
+	info, err := h.service.UpdateLink(r.Context(), req.Domain, short_url, req.update())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			respondWithError(w, http.StatusNotFound, "Short URL not found")
+		case errors.Is(err, service.ErrInvalidMetadata):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, auth.ErrUnauthenticated):
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, auth.ErrForbidden):
+			respondWithError(w, http.StatusForbidden, "Only the owner can update this short URL")
+		default:
+			h.log.ErrorContext(r.Context(), "failed to update short url", slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update short URL: %v", err))
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &Response{
+		Url:         info.ShortCode,
+		Title:       info.Title,
+		Description: info.Description,
+		Tags:        info.Tags,
+		Status:      http.StatusOK,
+	})
+}
+
+func (h *UrlHandler) HandleDeleteShortUrl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Only DELETE method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	short_url := r.URL.Query().Get("url")
+	if short_url == "" {
+		respondWithError(w, http.StatusBadRequest, "Incorrect or empty 'url' field")
+		return
+	}
+
+	if err := h.service.DeleteShortUrl(r.Context(), r.URL.Query().Get("domain"), short_url); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			respondWithError(w, http.StatusNotFound, "Short URL not found")
+		case errors.Is(err, auth.ErrUnauthenticated):
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, auth.ErrForbidden):
+			respondWithError(w, http.StatusForbidden, "Only the owner can delete this short URL")
+		default:
+			h.log.ErrorContext(r.Context(), "failed to delete short url", slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete short URL: %v", err))
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &Response{Url: short_url, Status: http.StatusOK})
+}
+
+const (
+	// "/{code}+" or "/{code}?preview=1" shows the preview page
+	previewSuffix = "+"
+	previewParam  = "preview"
+	// "/{code}?continue=1" skips the interstitial
+	continueParam = "continue"
+)
+
+// HandleRedirect redirects to the original URL. Password protected links
+// get a password form on GET which is submitted back with POST. Links with
+// an interstitial and preview requests get a page showing the destination.
+func (h *UrlHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	shortCode := strings.TrimPrefix(r.URL.Path, "/")
+	preview := query.Get(previewParam) == "1"
+	if code, ok := strings.CutSuffix(shortCode, previewSuffix); ok {
+		shortCode = code
+		preview = true
+	}
+	if shortCode == "" {
+		http.Error(w, "Short code is missing", http.StatusBadRequest)
+		return
+	}
+
+	domain, ok := h.service.DomainForHost(r.Host)
+	if !ok {
+		switch h.opts.UnknownHost {
+		case UnknownHostNotFound:
+			http.NotFound(w, r)
+			return
+		case UnknownHostDefault:
+			domain = ""
+		default:
+			http.Redirect(w, r, h.opts.UnknownHost, http.StatusFound)
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		h.handlePasswordSubmit(w, r, domain, shortCode)
+		return
+	}
+
+	info, err := h.service.GetLinkInfo(r.Context(), domain, shortCode)
+	if errors.Is(err, service.ErrPasswordRequired) {
+		renderPasswordForm(w, http.StatusOK, shortCode, "")
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.log.ErrorContext(r.Context(), "failed to resolve short code", slog.String("code", shortCode), slog.Any("error", err))
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	if preview || (info.Interstitial && query.Get(continueParam) != "1") {
+		renderPreview(w, info, info.Interstitial && !preview)
+		return
+	}
+
+	h.service.RecordClick(r.Context(), domain, shortCode)
+	// Not cached: every visit has to be counted and see the current link,
+	// interstitial policy and deletion
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.Redirect(w, r, info.OriginURL, http.StatusFound)
+}
+
+// formTags accepts tags both as repeated fields and comma separated.
+func formTags(values []string) []string {
+	var tags []string
+	for _, v := range values {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+	return tags
+}
+
+func isTrue(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, Response{Error: message, Status: code})
+}
+
+func respondWithReason(w http.ResponseWriter, code int, reason, message string) {
+	respondWithJSON(w, code, Response{Error: message, Status: code, Reason: reason})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/service"
+)
+
+type LinkResponse struct {
+	Domain    string `json:"domain"`
+	ShortCode string `json:"short_code"`
+	ShortURL  string `json:"short_url"`
+	// Empty for password protected links of other owners
+	OriginURL    string    `json:"origin_url,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Clicks       int64     `json:"clicks"`
+	Tags         []string  `json:"tags"`
+	Protected    bool      `json:"protected"`
+	Interstitial bool      `json:"interstitial"`
+}
+
+// HandleListLinks serves a page of links of the caller's API key. Query
+// parameters: short_domain (substring of the short domain name), owner (admin keys only), tag, sort
+// (created|clicks), limit, cursor.
+func (h *UrlHandler) HandleListLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, ok := h.listLinks(w, r)
+	if !ok {
+		return
+	}
+
+	resp := linkListResponse(page)
+	resp.Status = http.StatusOK
+	respondWithJSON(w, http.StatusOK, &resp)
+}
+
+// listLinks fetches the page requested by the query parameters, or answers
+// the request with an error and returns false.
+func (h *UrlHandler) listLinks(w http.ResponseWriter, r *http.Request) (service.LinkPage, bool) {
+	query := r.URL.Query()
+	opts := service.ListOptions{
+		ShortDomain: query.Get("short_domain"),
+		Owner:       query.Get("owner"),
+		Tag:         query.Get("tag"),
+		Sort:        query.Get("sort"),
+		Cursor:      query.Get("cursor"),
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid 'limit' parameter")
+			return service.LinkPage{}, false
+		}
+		opts.Limit = limit
+	}
+
+	page, err := h.service.ListLinks(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidListOptions) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else if errors.Is(err, auth.ErrUnauthenticated) {
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+		} else if errors.Is(err, auth.ErrForbidden) {
+			respondWithError(w, http.StatusForbidden, "Only admin API keys can list links of other owners")
+		} else {
+			h.log.ErrorContext(r.Context(), "failed to list links", slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list links: %v", err))
+		}
+		return service.LinkPage{}, false
+	}
+	return page, true
+}
+
+func linkResponse(info service.LinkInfo) LinkResponse {
+	tags := info.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return LinkResponse{
+		Domain:       info.Domain,
+		ShortCode:    info.ShortCode,
+		ShortURL:     info.ShortURL,
+		OriginURL:    info.OriginURL,
+		Title:        info.Title,
+		Description:  info.Description,
+		Owner:        info.Owner,
+		CreatedAt:    info.CreatedAt,
+		Clicks:       info.Clicks,
+		Tags:         tags,
+		Protected:    info.Protected,
+		Interstitial: info.Interstitial,
+	}
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/realip"
+)
+
+// Middleware wraps a handler.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in the middlewares, the first one outermost.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Recover turns panics of handlers into 500 responses and logs them with
+// the stack. http.ErrAbortHandler is passed on, it aborts the response on
+// purpose.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				logger.ErrorContext(r.Context(), "panic in HTTP handler",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())))
+				if rec.status == 0 {
+					respondWithError(w, http.StatusInternalServerError, "Internal server error")
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// RealIP resolves the client address once and stores it in the request
+// context for rate limiting and logging.
+func RealIP(ips *realip.Resolver) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := realip.WithClientIP(r.Context(), ips.FromRequest(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Timeout answers 503 Service Unavailable when next has not responded
+// within d, using http.TimeoutHandler. The request context is done at the
+// same time, but store calls take no context: the handler keeps running
+// until it returns on its own and its late writes are dropped. The response is buffered until next
+// returns, so handlers behind Timeout cannot flush or hijack the
+// connection. Zero disables the timeout.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		body, _ := json.Marshal(Response{Error: "Request timed out", Status: http.StatusServiceUnavailable})
+		h := http.TimeoutHandler(next, d, string(body))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(&timeoutWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// timeoutWriter marks the JSON body of http.TimeoutHandler, which writes it
+// without a Content-Type.
+type timeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// RequestID takes the request ID from the X-Request-ID header or generates a
+// new one, stores it in the request context and echoes it back to the client.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.SanitizeRequestID(r.Header.Get(logging.RequestIDHeader))
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// RateLimit rejects requests exceeding the limiter's budget for the client
+// with 429 Too Many Requests and a Retry-After header. Limiter failures are
+// logged and let the request through.
+func RateLimit(limiter ratelimit.Limiter, ips *realip.Resolver, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ratelimit.ClientKey(r.Context(), ips.FromRequest(r))
+
+			allowed, retryAfter, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.WarnContext(r.Context(), "rate limiter failed, allowing request", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				logger.DebugContext(r.Context(), "rate limit exceeded", slog.String("client", key))
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+				respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded, try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAPIKey rejects requests without a valid "Authorization: Bearer <key>"
+// header with 401 and stores the authenticated key in the request context.
+func RequireAPIKey(authn *auth.Authenticator, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := authn.Authenticate(auth.TokenFromAuthorization(r.Header.Get("Authorization")))
+			if err != nil {
+				if errors.Is(err, auth.ErrUnauthenticated) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="url_shortener"`)
+					respondWithError(w, http.StatusUnauthorized, err.Error())
+					return
+				}
+				logger.ErrorContext(r.Context(), "failed to authenticate API key", slog.Any("error", err))
+				respondWithError(w, http.StatusInternalServerError, "Failed to authenticate")
+				return
+			}
+
+			ctx := auth.WithKey(r.Context(), key)
+			ctx = ratelimit.WithClientID(ctx, key.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAdmin rejects requests whose API key, stored by RequireAPIKey, is
+// not an admin key with 403 Forbidden.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := auth.KeyFromContext(r.Context()); !ok || !key.Admin {
+			respondWithError(w, http.StatusForbidden, "Admin API key required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
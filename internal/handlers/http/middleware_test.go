@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("late"))
+	}))
+
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, httptest.NewRequest("GET", "/abc", nil))
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || resp.Status != http.StatusServiceUnavailable ||
+		rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("slow handler: status %d, %+v, Content-Type %q", rec.Code, resp, rec.Header().Get("Content-Type"))
+	}
+
+	fast := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusFound)
+	}))
+	rec = httptest.NewRecorder()
+	fast.ServeHTTP(rec, httptest.NewRequest("GET", "/abc", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com" {
+		t.Errorf("fast handler: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	authn := auth.NewAuthenticator(auth.NewInMemoryKeyStore())
+	_, user, err := authn.CreateKey("user", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, err := authn.CreateKey("admin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := RequireAPIKey(authn, logging.Discard())(RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	for token, want := range map[string]int{"": 401, user: 403, admin: 204} {
+		req := httptest.NewRequest("POST", "/admin/reload", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("token %q: status %d, want %d", token, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+)
+
+// openAPISpec describes V1Routes. openapi_test.go checks that both agree.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// HandleOpenAPI serves the OpenAPI 3 document of the REST API.
+func (h *UrlHandler) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(openAPISpec)))
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+type specDoc struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]specSchema   `json:"schemas"`
+		Responses map[string]specResponse `json:"responses"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	Responses map[string]specResponse `json:"responses"`
+}
+
+type specResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema specSchema `json:"schema"`
+	} `json:"content"`
+}
+
+type specSchema struct {
+	Ref        string                `json:"$ref"`
+	Type       string                `json:"type"`
+	Required   []string              `json:"required"`
+	Properties map[string]specSchema `json:"properties"`
+	Items      *specSchema           `json:"items"`
+}
+
+var specMethods = []string{"get", "post", "put", "patch", "delete"}
+
+func loadSpec(t *testing.T) specDoc {
+	t.Helper()
+	var doc specDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return doc
+}
+
+// operation returns the operation of a route pattern such as
+// "GET /api/v1/links/{code}".
+func (doc specDoc) operation(t *testing.T, pattern string) (specOperation, bool) {
+	t.Helper()
+	method, path, _ := strings.Cut(pattern, " ")
+	raw, ok := doc.Paths[strings.TrimPrefix(path, APIPrefix)][strings.ToLower(method)]
+	if !ok {
+		return specOperation{}, false
+	}
+	var op specOperation
+	if err := json.Unmarshal(raw, &op); err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	return op, true
+}
+
+func (doc specDoc) schema(s specSchema) specSchema {
+	if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+		return doc.schema(doc.Components.Schemas[name])
+	}
+	return s
+}
+
+func (doc specDoc) response(r specResponse) specResponse {
+	if name, ok := strings.CutPrefix(r.Ref, "#/components/responses/"); ok {
+		return doc.response(doc.Components.Responses[name])
+	}
+	return r
+}
+
+// validate reports where value does not match the schema.
+func (doc specDoc) validate(t *testing.T, where string, s specSchema, value any) {
+	t.Helper()
+	s = doc.schema(s)
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: want object, got %T", where, value)
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				t.Errorf("%s: missing required property %q", where, name)
+			}
+		}
+		if s.Properties == nil {
+			return
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				t.Errorf("%s: undocumented property %q", where, name)
+				continue
+			}
+			doc.validate(t, where+"."+name, prop, v)
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			t.Errorf("%s: want array, got %T", where, value)
+			return
+		}
+		for i, v := range arr {
+			doc.validate(t, where+"["+strconv.Itoa(i)+"]", *s.Items, v)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: want string, got %T", where, value)
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != float64(int64(f)) {
+			t.Errorf("%s: want integer, got %v", where, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: want boolean, got %T", where, value)
+		}
+	}
+}
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	doc := loadSpec(t)
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != APIPrefix {
+		t.Errorf("servers = %+v, want %s", doc.Servers, APIPrefix)
+	}
+
+	h := NewUrlHandler(nil, Options{}, logging.Discard())
+	routes := map[string]bool{}
+	for _, route := range h.V1Routes() {
+		routes[route.Pattern] = true
+		if _, ok := doc.operation(t, route.Pattern); !ok {
+			t.Errorf("route %q is not documented", route.Pattern)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if !slices.Contains(specMethods, method) {
+				continue
+			}
+			pattern := strings.ToUpper(method) + " " + APIPrefix + path
+			if !routes[pattern] {
+				t.Errorf("documented operation %q has no route", pattern)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDescribesResponses(t *testing.T) {
+	doc := loadSpec(t)
+	logger := logging.Discard()
+
+	authn := auth.NewAuthenticator(auth.NewInMemoryKeyStore())
+	ownerKey, owner, err := authn.CreateKey("owner", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := authn.CreateKey("other", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, err := authn.CreateKey("admin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := service.NewUrlService(storage.NewInMemoryStore(logger), service.NewDomains("https", "sho.rt"), service.Options{}, logger)
+	h := NewUrlHandler(svc, Options{MaxBodyBytes: 1024}, logger)
+	mux := http.NewServeMux()
+	for _, route := range h.V1Routes() {
+		var handler http.Handler = route.Handler
+		if route.Auth {
+			handler = RequireAPIKey(authn, logger)(handler)
+		}
+		mux.Handle(route.Pattern, handler)
+	}
+
+	codes := map[string]string{"owner": ownerKey.ID}
+	steps := []struct {
+		name        string
+		method      string
+		path        string
+		key         string
+		contentType string
+		body        string
+		want        int
+		// saves short_code of the response under this name
+		save string
+	}{
+		{name: "spec", method: "GET", path: "/openapi.json", want: 200},
+		{name: "create json", method: "POST", path: "/links", key: owner, contentType: "application/json",
+			body: `{"url":"https://example.com/a","title":"A","tags":["x","y"]}`, want: 201, save: "a"},
+		{name: "create form", method: "POST", path: "/links", key: owner, contentType: "application/x-www-form-urlencoded",
+			body: "url=https%3A%2F%2Fexample.com%2Fp&password=secret", want: 201, save: "protected"},
+		{name: "create without key", method: "POST", path: "/links", contentType: "application/json",
+			body: `{"url":"https://example.com/a"}`, want: 401},
+		{name: "create without url", method: "POST", path: "/links", key: owner, contentType: "application/json",
+			body: `{"title":"A"}`, want: 400},
+		{name: "create unknown field", method: "POST", path: "/links", key: owner, contentType: "application/json",
+			body: `{"url":"https://example.com/a","color":"red"}`, want: 400},
+		{name: "create unknown domain", method: "POST", path: "/links", key: owner, contentType: "application/json",
+			body: `{"url":"https://example.com/a","domain":"other.example"}`, want: 400},
+		{name: "create text body", method: "POST", path: "/links", key: owner, contentType: "text/plain",
+			body: "https://example.com/a", want: 415},
+		{name: "create large body", method: "POST", path: "/links", key: owner, contentType: "application/json",
+			body: `{"url":"https://example.com/` + strings.Repeat("a", 2048) + `"}`, want: 413},
+		{name: "get", method: "GET", path: "/links/{a}", want: 200},
+		{name: "get protected", method: "GET", path: "/links/{protected}", want: 403},
+		{name: "get missing", method: "GET", path: "/links/missing", want: 404},
+		{name: "resolve", method: "POST", path: "/links/{protected}/resolve", contentType: "application/json",
+			body: `{"password":"secret"}`, want: 200},
+		{name: "resolve form", method: "POST", path: "/links/{protected}/resolve", contentType: "application/x-www-form-urlencoded",
+			body: "password=secret", want: 200},
+		{name: "resolve wrong password", method: "POST", path: "/links/{protected}/resolve", contentType: "application/json",
+			body: `{"password":"wrong"}`, want: 403},
+		{name: "resolve without password", method: "POST", path: "/links/{protected}/resolve", contentType: "application/json",
+			body: `{}`, want: 403},
+		{name: "resolve plain", method: "POST", path: "/links/{a}/resolve", contentType: "application/json",
+			body: `{}`, want: 200},
+		{name: "resolve missing", method: "POST", path: "/links/missing/resolve", contentType: "application/json",
+			body: `{"password":"secret"}`, want: 404},
+		{name: "get unknown domain", method: "GET", path: "/links/{a}?domain=other.example", want: 404},
+		{name: "update by other", method: "PATCH", path: "/links/{a}", key: other, contentType: "application/json",
+			body: `{"title":"B"}`, want: 403},
+		{name: "update", method: "PATCH", path: "/links/{a}", key: owner, contentType: "application/json",
+			body: `{"title":"B","tags":[]}`, want: 200},
+		{name: "update form", method: "PATCH", path: "/links/{a}", key: owner, contentType: "application/x-www-form-urlencoded",
+			body: "description=about", want: 200},
+		{name: "update invalid", method: "PATCH", path: "/links/{a}", key: owner, contentType: "application/json",
+			body: `{"title":"` + strings.Repeat("t", 201) + `"}`, want: 400},
+		{name: "update missing", method: "PATCH", path: "/links/missing", key: owner, contentType: "application/json",
+			body: `{"title":"B"}`, want: 404},
+		{name: "list", method: "GET", path: "/links", key: other, want: 200},
+		{name: "list page", method: "GET", path: "/links?limit=1&sort=clicks", key: owner, want: 200},
+		{name: "list invalid", method: "GET", path: "/links?sort=name", key: owner, want: 400},
+		{name: "list other owner", method: "GET", path: "/links?owner={owner}", key: other, want: 403},
+		{name: "list as admin", method: "GET", path: "/links?owner={owner}", key: admin, want: 200},
+		{name: "list without key", method: "GET", path: "/links", want: 401},
+		{name: "delete by other", method: "DELETE", path: "/links/{a}", key: other, want: 403},
+		{name: "delete", method: "DELETE", path: "/links/{a}", key: owner, want: 204},
+		{name: "delete again", method: "DELETE", path: "/links/{a}", key: owner, want: 404},
+	}
+
+	for _, step := range steps {
+		path := step.path
+		for name, code := range codes {
+			path = strings.ReplaceAll(path, "{"+name+"}", code)
+		}
+		req := httptest.NewRequest(step.method, APIPrefix+path, strings.NewReader(step.body))
+		if step.contentType != "" {
+			req.Header.Set("Content-Type", step.contentType)
+		}
+		if step.key != "" {
+			req.Header.Set("Authorization", "Bearer "+step.key)
+		}
+
+		_, pattern := mux.Handler(req)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != step.want {
+			t.Errorf("%s: status %d, want %d: %s", step.name, resp.StatusCode, step.want, body)
+			continue
+		}
+
+		op, ok := doc.operation(t, pattern)
+		if !ok {
+			t.Errorf("%s: operation %q is not documented", step.name, pattern)
+			continue
+		}
+		documented, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+		if !ok {
+			t.Errorf("%s: status %d of %q is not documented", step.name, resp.StatusCode, pattern)
+			continue
+		}
+		documented = doc.response(documented)
+
+		if len(documented.Content) == 0 {
+			if len(body) != 0 {
+				t.Errorf("%s: documented without content, got %s", step.name, body)
+			}
+			continue
+		}
+		media, ok := documented.Content[resp.Header.Get("Content-Type")]
+		if !ok {
+			t.Errorf("%s: content type %q is not documented", step.name, resp.Header.Get("Content-Type"))
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			t.Errorf("%s: invalid JSON response: %v", step.name, err)
+			continue
+		}
+		doc.validate(t, step.name, media.Schema, value)
+
+		if step.save != "" {
+			code, _ := value.(map[string]any)["short_code"].(string)
+			codes[step.save] = code
+			if want := APIPrefix + "/links/" + code; resp.Header.Get("Location") != want {
+				t.Errorf("%s: Location %q, want %q", step.name, resp.Header.Get("Location"), want)
+			}
+		}
+	}
+}
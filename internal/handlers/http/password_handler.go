@@ -0,0 +1,94 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/ratelimit"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// 72 bytes of bcrypt input plus form encoding overhead
+const maxPasswordFormSize = 1 << 10
+
+var passwordFormTmpl = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; }
+input, button { font-size: 1rem; padding: .5rem; width: 100%; box-sizing: border-box; margin-top: .5rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Protected link</h1>
+<p>Enter the password to open <code>{{.Code}}</code>.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+func renderPasswordForm(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	passwordFormTmpl.Execute(w, struct{ Code, Error string }{code, message})
+}
+
+func (h *UrlHandler) handlePasswordSubmit(w http.ResponseWriter, r *http.Request, domain, shortCode string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		renderPasswordForm(w, http.StatusBadRequest, shortCode, "Invalid form submission.")
+		return
+	}
+
+	if retryAfter, ok := h.allowPasswordAttempt(r, domain, shortCode); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+		renderPasswordForm(w, http.StatusTooManyRequests, shortCode, "Too many attempts, try again later.")
+		return
+	}
+
+	info, err := h.service.ResolveWithPassword(r.Context(), domain, shortCode, r.PostForm.Get("password"))
+	switch {
+	case err == nil:
+		// 303 so that browsers do not cache the redirect of a protected link
+		h.service.RecordClick(r.Context(), domain, shortCode)
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, info.OriginURL, http.StatusSeeOther)
+	case errors.Is(err, service.ErrInvalidPassword), errors.Is(err, service.ErrPasswordRequired):
+		renderPasswordForm(w, http.StatusUnauthorized, shortCode, "Wrong password.")
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+		http.NotFound(w, r)
+	default:
+		h.log.ErrorContext(r.Context(), "failed to resolve protected link", slog.String("code", shortCode), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// allowPasswordAttempt reports whether the client may try a password for
+// the link, and if not, how long it has to wait.
+func (h *UrlHandler) allowPasswordAttempt(r *http.Request, domain, shortCode string) (time.Duration, bool) {
+	if h.opts.PasswordAttempts == nil {
+		return 0, true
+	}
+	key := ratelimit.ClientKey(r.Context(), h.opts.ClientIPs.FromRequest(r)) + "|" + domain + "/" + shortCode
+	allowed, retryAfter, err := h.opts.PasswordAttempts.Allow(r.Context(), key)
+	if err != nil {
+		h.log.WarnContext(r.Context(), "password attempt limiter failed, allowing request", slog.Any("error", err))
+		return 0, true
+	}
+	return retryAfter, allowed
+}
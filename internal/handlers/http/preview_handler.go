@@ -0,0 +1,69 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/vadyaov/url_shortener/internal/service"
+)
+
+var previewTmpl = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Interstitial}}You are leaving {{.Info.Domain}}{{else}}Link preview{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 36rem; margin: 4rem auto; padding: 0 1rem; }
+dt { font-weight: bold; margin-top: .75rem; }
+dd { margin: 0; word-break: break-all; }
+.button { display: inline-block; margin-top: 1.5rem; padding: .6rem 1.2rem; background: #1a73e8; color: #fff; text-decoration: none; border-radius: 4px; }
+</style>
+</head>
+<body>
+{{if .Interstitial}}
+<h1>You are leaving {{.Info.Domain}}</h1>
+<p>This link points to an external site. Check the destination before you continue.</p>
+{{else}}
+<h1>Link preview</h1>
+{{end}}
+{{with .Info.Title}}<h2>{{.}}</h2>{{end}}
+{{with .Info.Description}}<p>{{.}}</p>{{end}}
+<dl>
+<dt>Short link</dt><dd>{{.Info.ShortURL}}</dd>
+<dt>Destination</dt><dd>{{.Info.OriginURL}}</dd>
+<dt>Destination domain</dt><dd>{{.DestinationHost}}</dd>
+<dt>Created</dt><dd>{{.Info.CreatedAt.Format "2 January 2006"}}</dd>
+<dt>Clicks</dt><dd>{{.Info.Clicks}}</dd>
+{{with .Info.Tags}}<dt>Tags</dt><dd>{{range $i, $t := .}}{{if $i}}, {{end}}{{$t}}{{end}}</dd>{{end}}
+</dl>
+<a class="button" href="{{.ContinueURL}}" rel="noreferrer">Continue to {{.DestinationHost}}</a>
+</body>
+</html>
+`))
+
+// renderPreview shows where a link goes instead of redirecting. interstitial
+// selects the wording used when the page is shown on a plain visit.
+func renderPreview(w http.ResponseWriter, info service.LinkInfo, interstitial bool) {
+	var host string
+	if u, err := url.Parse(info.OriginURL); err == nil {
+		host = u.Hostname()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	previewTmpl.Execute(w, struct {
+		Info            service.LinkInfo
+		DestinationHost string
+		ContinueURL     string
+		Interstitial    bool
+	}{
+		Info:            info,
+		DestinationHost: host,
+		ContinueURL:     "/" + url.PathEscape(info.ShortCode) + "?" + continueParam + "=1",
+		Interstitial:    interstitial,
+	})
+}
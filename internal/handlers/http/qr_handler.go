@@ -0,0 +1,109 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/qrcode"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// HandleQrCode serves GET /{code}/qr. The domain is taken from the Host
+// header like for redirects, or from the "domain" query parameter.
+// Query parameters: format (png|svg), size, ec (L|M|Q|H), margin, fg, bg.
+func (h *UrlHandler) HandleQrCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortCode := r.PathValue("code")
+	if shortCode == "" {
+		respondWithError(w, http.StatusBadRequest, "Short code is missing")
+		return
+	}
+
+	query := r.URL.Query()
+	opts, err := qrOptionsFromQuery(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain := query.Get("domain")
+	if domain == "" {
+		domain, _ = h.service.DomainForHost(r.Host)
+	}
+
+	img, err := h.service.GetQrCode(r.Context(), domain, shortCode, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, service.ErrUnknownDomain):
+			respondWithError(w, http.StatusNotFound, "Short URL not found")
+		case errors.Is(err, qrcode.ErrInvalidOptions):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			h.log.ErrorContext(r.Context(), "failed to render QR code", slog.String("code", shortCode), slog.Any("error", err))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to render QR code: %v", err))
+		}
+		return
+	}
+
+	// Caches must ask again every time: the link may have been deleted
+	// since. The ETag turns the repeated requests into 304 responses.
+	sum := sha256.Sum256(img)
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img))
+}
+
+func qrOptionsFromQuery(query url.Values) (qrcode.Options, error) {
+	opts := qrcode.DefaultOptions()
+
+	if v := query.Get("format"); v != "" {
+		opts.Format = v
+	}
+	if v := query.Get("ec"); v != "" {
+		opts.Level = v
+	}
+	if v := query.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: size must be a number", qrcode.ErrInvalidOptions)
+		}
+		opts.Size = size
+	}
+	if v := query.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: margin must be a number", qrcode.ErrInvalidOptions)
+		}
+		opts.Margin = margin
+	}
+	if v := query.Get("fg"); v != "" {
+		c, err := qrcode.ParseColor(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Foreground = c
+	}
+	if v := query.Get("bg"); v != "" {
+		c, err := qrcode.ParseColor(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = c
+	}
+
+	return opts, opts.Validate()
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+func TestHandleQrCode(t *testing.T) {
+	logger := logging.Discard()
+	svc := service.NewUrlService(storage.NewInMemoryStore(logger), service.NewDomains("https", "sho.rt"), service.Options{}, logger)
+	h := NewUrlHandler(svc, Options{}, logger)
+	code, err := svc.GetShortUrl(context.Background(), "https://example.com/qr", service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		code        string
+		query       string
+		want        int
+		contentType string
+		// width of the decoded PNG, 0 for other responses
+		size int
+	}{
+		{name: "default", code: code, want: 200, contentType: "image/png", size: 256},
+		{name: "min size, max margin", code: code, query: "?size=64&margin=16&ec=H", want: 200, contentType: "image/png", size: 64},
+		{name: "max size, no margin", code: code, query: "?size=2048&margin=0&ec=L&fg=102030&bg=ffffff00", want: 200, contentType: "image/png", size: 2048},
+		{name: "svg", code: code, query: "?format=svg", want: 200, contentType: "image/svg+xml"},
+		{name: "size not a number", code: code, query: "?size=big", want: 400},
+		{name: "size too small", code: code, query: "?size=16", want: 400},
+		{name: "margin too large", code: code, query: "?margin=17", want: 400},
+		{name: "bad color", code: code, query: "?fg=red", want: 400},
+		{name: "bad level", code: code, query: "?ec=X", want: 400},
+		{name: "unknown code", code: "missing", want: 404},
+		{name: "unknown domain", code: code, query: "?domain=other.example", want: 404},
+		{name: "post", method: http.MethodPost, code: code, want: 405},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "http://sho.rt/"+tt.code+"/qr"+tt.query, nil)
+			req.SetPathValue("code", tt.code)
+			rec := httptest.NewRecorder()
+			h.HandleQrCode(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.contentType)
+			}
+			if tt.size == 0 {
+				return
+			}
+			img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if img.Bounds() != image.Rect(0, 0, tt.size, tt.size) {
+				t.Errorf("bounds = %v, want %dx%d", img.Bounds(), tt.size, tt.size)
+			}
+		})
+	}
+}
+
+func TestQrCodeCaching(t *testing.T) {
+	logger := logging.Discard()
+	store := storage.NewInMemoryStore(logger)
+	svc := service.NewUrlService(store, service.NewDomains("https", "sho.rt"), service.Options{}, logger)
+	h := NewUrlHandler(svc, Options{}, logger)
+	code, err := svc.GetShortUrl(context.Background(), "https://example.com/qr", service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(query, etag string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://sho.rt/"+code+"/qr"+query, nil)
+		req.SetPathValue("code", code)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleQrCode(rec, req)
+		return rec
+	}
+
+	rec := get("", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != 200 || etag == "" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("status %d, ETag %q, Cache-Control %q", rec.Code, etag, rec.Header().Get("Cache-Control"))
+	}
+	if rec := get("", etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("revalidation: status %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec := get("?format=svg", etag); rec.Code != 200 || rec.Header().Get("ETag") == etag {
+		t.Errorf("other format: status %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	// a deleted link is not served from a revalidating cache
+	if err := store.DeleteURL("", code); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("", etag); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted link: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/vadyaov/url_shortener/internal/config"
+)
+
+type ReloadHandler struct {
+	reload func() (config.Result, error)
+}
+
+// NewReloadHandler serves reloads done by reload, which re-reads the
+// configuration of the running application and logs the outcome.
+func NewReloadHandler(reload func() (config.Result, error)) *ReloadHandler {
+	return &ReloadHandler{reload: reload}
+}
+
+// HandleReload reloads the configuration like SIGHUP and responds with
+// the changed settings. Invalid settings fail with 422, nothing changes
+// then.
+func (h *ReloadHandler) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := h.reload()
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vadyaov/url_shortener/internal/service"
+)
+
+// DefaultMaxBodyBytes is the request body limit when Options.MaxBodyBytes
+// is zero.
+const DefaultMaxBodyBytes = 64 << 10
+
+// createRequest is the body of create requests. Form bodies use the same
+// field names, with tags repeated or comma separated.
+type createRequest struct {
+	URL          string   `json:"url"`
+	Domain       string   `json:"domain"`
+	Password     string   `json:"password"`
+	Interstitial bool     `json:"interstitial"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Tags         []string `json:"tags"`
+}
+
+func (c *createRequest) fromForm(form url.Values) {
+	c.URL = form.Get("url")
+	c.Domain = form.Get("domain")
+	c.Password = form.Get("password")
+	c.Interstitial = isTrue(form.Get("interstitial"))
+	c.Title = form.Get("title")
+	c.Description = form.Get("description")
+	c.Tags = formTags(form["tags"])
+}
+
+// resolveRequest is the body of POST /api/v1/links/{code}/resolve.
+type resolveRequest struct {
+	Password string `json:"password"`
+}
+
+func (p *resolveRequest) fromForm(form url.Values) {
+	p.Password = form.Get("password")
+}
+
+// lookupRequest names a short link, in the query or the body.
+type lookupRequest struct {
+	URL    string `json:"url"`
+	Domain string `json:"domain"`
+}
+
+func (l *lookupRequest) fromForm(form url.Values) {
+	l.URL = form.Get("url")
+	l.Domain = form.Get("domain")
+}
+
+// linkPatch changes the metadata fields which are present.
+type linkPatch struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+}
+
+func (u *linkPatch) fromForm(form url.Values) {
+	if _, ok := form["title"]; ok {
+		title := form.Get("title")
+		u.Title = &title
+	}
+	if _, ok := form["description"]; ok {
+		description := form.Get("description")
+		u.Description = &description
+	}
+	if values, ok := form["tags"]; ok {
+		tags := formTags(values)
+		u.Tags = &tags
+	}
+}
+
+func (u linkPatch) update() service.LinkUpdate {
+	return service.LinkUpdate{Title: u.Title, Description: u.Description, Tags: u.Tags}
+}
+
+// updateRequest names the link to patch in the body.
+type updateRequest struct {
+	URL    string `json:"url"`
+	Domain string `json:"domain"`
+	linkPatch
+}
+
+func (u *updateRequest) fromForm(form url.Values) {
+	u.URL = form.Get("url")
+	u.Domain = form.Get("domain")
+	u.linkPatch.fromForm(form)
+}
+
+// decodeRequest fills dst from a JSON body, or through fromForm from the
+// query and a form body. Requests without a body are read from the query.
+// It answers the request and returns false if the body is too large,
+// malformed or of an unsupported content type.
+func (h *UrlHandler) decodeRequest(w http.ResponseWriter, r *http.Request, dst any, fromForm func(url.Values)) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.opts.MaxBodyBytes)
+
+	var mediaType string
+	if ct := r.Header.Get("Content-Type"); ct != "" && r.ContentLength != 0 {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			mediaType = ct
+		}
+	}
+
+	var err error
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if err = decodeJSON(r.Body, dst); err == nil {
+			return true
+		}
+	case mediaType == "multipart/form-data":
+		err = r.ParseMultipartForm(h.opts.MaxBodyBytes)
+	case mediaType == "" || mediaType == "application/x-www-form-urlencoded":
+		err = r.ParseForm()
+	default:
+		respondWithError(w, http.StatusUnsupportedMediaType,
+			fmt.Sprintf("Unsupported content type '%s', use application/json or application/x-www-form-urlencoded", mediaType))
+		return false
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	case err != nil:
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing request body: %v", err))
+		return false
+	}
+	fromForm(r.Form)
+	return true
+}
+
+// decodeJSON reads exactly one JSON object with known fields.
+func decodeJSON(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("empty body")
+		}
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return err
+		}
+		return errors.New("unexpected data after JSON object")
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package handoff passes listening sockets between processes, so that a new
+// binary takes over the ports without refusing connections while the old
+// one drains its requests. It also accepts sockets passed by systemd socket
+// activation.
+//
+// Sockets are passed in the systemd format: file descriptors from 3 on,
+// counted by LISTEN_FDS and named by LISTEN_FDNAMES. systemd sets
+// LISTEN_PID to the receiving process; a process started by Upgrade gets
+// the PID of its parent in HandoffParentEnv instead, which it cannot know
+// in advance.
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PID of the process handing over its sockets
+	HandoffParentEnv = "SHORTENER_HANDOFF_PARENT"
+	// Descriptor of the pipe on which the new process reports it is ready
+	HandoffReadyEnv = "SHORTENER_HANDOFF_READY_FD"
+
+	listenFDsStart = 3
+)
+
+// Notify relays the upgrade signal, SIGUSR2, to c. It does nothing on
+// systems without the signal.
+func Notify(c chan<- os.Signal) {
+	if len(upgradeSignals) > 0 {
+		signal.Notify(c, upgradeSignals...)
+	}
+}
+
+// Set holds the listeners of the process, the inherited ones and those
+// opened by Listen.
+type Set struct {
+	log *slog.Logger
+
+	mu        sync.Mutex
+	inherited map[string]net.Listener
+	active    map[string]net.Listener
+	names     []string
+	ready     *os.File // pipe to the parent process, nil if not upgraded
+	upgraded  bool
+}
+
+// Inherit takes the sockets passed by systemd or by a parent process.
+// Without such sockets the set is empty and Listen opens new ones. The
+// environment variables are cleared so that they do not leak into other
+// child processes.
+func Inherit(logger *slog.Logger) (*Set, error) {
+	s := &Set{log: logger, inherited: map[string]net.Listener{}, active: map[string]net.Listener{}}
+	defer func() {
+		for _, env := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", HandoffParentEnv, HandoffReadyEnv} {
+			os.Unsetenv(env)
+		}
+	}()
+
+	source := ""
+	switch {
+	case os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()):
+		source = "systemd"
+	case os.Getenv(HandoffParentEnv) != "" && os.Getenv(HandoffParentEnv) == strconv.Itoa(os.Getppid()):
+		source = "parent"
+	default:
+		return s, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := range n {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		// unnamed sockets of systemd are called "unknown"
+		if name == "" || name == "unknown" {
+			name = "fd" + strconv.Itoa(listenFDsStart+i)
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited socket %s: %w", name, err)
+		}
+		s.inherited[name] = l
+		logger.Info("Inherited listener", slog.String("name", name), slog.String("addr", l.Addr().String()), slog.String("from", source))
+	}
+
+	if fd := os.Getenv(HandoffReadyEnv); source == "parent" && fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", HandoffReadyEnv, fd)
+		}
+		s.ready = os.NewFile(uintptr(n), "handoff-ready")
+	}
+	return s, nil
+}
+
+// Listen returns the inherited listener called name or opens a new one on
+// addr. Unnamed inherited sockets are matched by address.
+func (s *Set) Listen(name, addr string) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.inherited[name]
+	if !ok {
+		l, ok = s.takeByAddr(addr)
+	}
+	if ok {
+		delete(s.inherited, name)
+	} else {
+		var err error
+		if l, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+	s.active[name] = l
+	s.names = append(s.names, name)
+	return l, nil
+}
+
+func (s *Set) takeByAddr(addr string) (net.Listener, bool) {
+	want, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, false
+	}
+	for name, l := range s.inherited {
+		got, ok := l.Addr().(*net.TCPAddr)
+		if ok && got.Port == want.Port && (want.IP == nil || want.IP.Equal(got.IP)) {
+			delete(s.inherited, name)
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// CloseUnused closes the inherited listeners no Listen call asked for,
+// e.g. the gRPC socket after switching to single port mode.
+func (s *Set) CloseUnused() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, l := range s.inherited {
+		s.log.Warn("Closing unused inherited listener", slog.String("name", name), slog.String("addr", l.Addr().String()))
+		l.Close()
+		delete(s.inherited, name)
+	}
+}
+
+// Ready tells the parent process, if any, that this process serves the
+// sockets now, so the parent can stop.
+func (s *Set) Ready() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready == nil {
+		return nil
+	}
+	_, err := s.ready.Write([]byte{1})
+	s.ready.Close()
+	s.ready = nil
+	return err
+}
+
+// Upgrade starts the current executable with the same arguments and the
+// listeners of the set and waits until it reports ready. The caller then
+// stops serving; the listening sockets stay open in the new process, so
+// no connection is refused meanwhile. Upgrade fails if the new process
+// exits or does not become ready within timeout, the old process keeps
+// serving then.
+func (s *Set) Upgrade(timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upgraded {
+		return errors.New("already upgraded")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	files := make([]*os.File, 0, len(s.names)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, name := range s.names {
+		l, ok := s.active[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s cannot be passed on", name)
+		}
+		f, err := l.File()
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", name, err)
+		}
+		files = append(files, f)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(s.names)),
+		"LISTEN_FDNAMES="+strings.Join(s.names, ":"),
+		HandoffParentEnv+"="+strconv.Itoa(os.Getpid()),
+		HandoffReadyEnv+"="+strconv.Itoa(listenFDsStart+len(s.names)),
+	)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.log.Info("Started new process for handoff", slog.Int("pid", cmd.Process.Pid))
+	// the write end must only stay open in the child, or a crashed child
+	// would not end the read below
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	readyC := make(chan error, 1)
+	go func() {
+		_, err := readyR.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = errors.New("new process closed the handoff pipe")
+		}
+		readyC <- err
+	}()
+
+	select {
+	case err := <-readyC:
+		if err != nil {
+			cmd.Process.Kill()
+			return err
+		}
+	case err := <-exited:
+		return fmt.Errorf("new process exited before becoming ready: %v", err)
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("new process not ready after %s", timeout)
+	}
+	s.upgraded = true
+	s.log.Info("New process took over the listeners", slog.Int("pid", cmd.Process.Pid))
+	return nil
+}
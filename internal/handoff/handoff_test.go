@@ -0,0 +1,199 @@
+//go:build unix
+
+package handoff
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+// helperEnv makes the test binary run helperProcess instead of the tests.
+// The helper listens on HANDOFF_TEST_LISTEN ("name=addr,...") and prints
+// "name addr" for each listener.
+const helperEnv = "HANDOFF_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) != "" {
+		if err := helperProcess(); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func helperProcess() error {
+	// systemd sets LISTEN_PID to the PID it starts
+	if os.Getenv(helperEnv) == "systemd" {
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	}
+	s, err := Inherit(logging.Discard())
+	if err != nil {
+		return err
+	}
+	for _, env := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", HandoffParentEnv, HandoffReadyEnv} {
+		if v := os.Getenv(env); v != "" {
+			return fmt.Errorf("%s=%s left in the environment", env, v)
+		}
+	}
+	for _, spec := range strings.Split(os.Getenv("HANDOFF_TEST_LISTEN"), ",") {
+		name, addr, _ := strings.Cut(spec, "=")
+		l, err := s.Listen(name, addr)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name, l.Addr())
+	}
+	s.CloseUnused()
+	return s.Ready()
+}
+
+// runHelper starts the helper with the listeners as inherited sockets and
+// returns the addresses it listened on by name.
+func runHelper(t *testing.T, mode string, env []string, listen string, listeners ...net.Listener) map[string]string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	for _, l := range listeners {
+		f, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	}
+	cmd.Env = append(os.Environ(), helperEnv+"="+mode, "HANDOFF_TEST_LISTEN="+listen)
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("helper: %v\n%s", err, out)
+	}
+
+	addrs := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		name, addr, _ := strings.Cut(scanner.Text(), " ")
+		addrs[name] = addr
+	}
+	return addrs
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestInheritSystemd(t *testing.T) {
+	http, grpc := listen(t), listen(t)
+	// the second socket is unnamed and found by its address
+	addrs := runHelper(t, "systemd", []string{"LISTEN_FDS=2", "LISTEN_FDNAMES=http:unknown"},
+		"http=127.0.0.1:0,grpc="+grpc.Addr().String(), http, grpc)
+	if addrs["http"] != http.Addr().String() || addrs["grpc"] != grpc.Addr().String() {
+		t.Errorf("helper listened on %v, want the inherited %s and %s", addrs, http.Addr(), grpc.Addr())
+	}
+}
+
+func TestInheritFromParent(t *testing.T) {
+	http := listen(t)
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readyR.Close()
+
+	env := []string{
+		"LISTEN_FDS=1", "LISTEN_FDNAMES=http",
+		HandoffParentEnv + "=" + strconv.Itoa(os.Getpid()),
+		HandoffReadyEnv + "=" + strconv.Itoa(listenFDsStart+1),
+	}
+	// the ready pipe follows the listener
+	f, err := http.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	helper := exec.Command(os.Args[0], "-test.run=^$")
+	helper.ExtraFiles = []*os.File{f, readyW}
+	helper.Env = append(append(os.Environ(), helperEnv+"=parent", "HANDOFF_TEST_LISTEN=http=127.0.0.1:0"), env...)
+	out, err := helper.CombinedOutput()
+	readyW.Close()
+	if err != nil {
+		t.Fatalf("helper: %v\n%s", err, out)
+	}
+	if want := "http " + http.Addr().String(); strings.TrimSpace(string(out)) != want {
+		t.Errorf("helper printed %q, want %q", out, want)
+	}
+
+	readyR.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if n, err := readyR.Read(make([]byte, 1)); n != 1 {
+		t.Errorf("no ready byte from the helper: %v", err)
+	}
+}
+
+func TestInheritIgnoresOtherProcesses(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+	}{
+		{"no variables", nil},
+		{"LISTEN_PID of another process", []string{"LISTEN_PID=1", "LISTEN_FDS=1"}},
+		{"parent which is not ours", []string{HandoffParentEnv + "=1", "LISTEN_FDS=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := listen(t)
+			addrs := runHelper(t, "plain", tt.env, "http=127.0.0.1:0", l)
+			if addrs["http"] == "" || addrs["http"] == l.Addr().String() {
+				t.Errorf("helper listened on %q, want a fresh socket", addrs["http"])
+			}
+		})
+	}
+}
+
+func TestInheritInvalidListenFDs(t *testing.T) {
+	for _, fds := range []string{"", "two", "-1"} {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", fds)
+		if _, err := Inherit(logging.Discard()); err == nil || !strings.Contains(err.Error(), "invalid LISTEN_FDS") {
+			t.Errorf("LISTEN_FDS=%q: err = %v", fds, err)
+		}
+		if v, ok := os.LookupEnv("LISTEN_PID"); ok {
+			t.Errorf("LISTEN_PID=%s left in the environment", v)
+		}
+	}
+}
+
+func TestListenFresh(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+	s, err := Inherit(logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.Listen("http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if port := l.Addr().(*net.TCPAddr).Port; port == 0 {
+		t.Errorf("listener on %s", l.Addr())
+	}
+	// no parent waits for the new process
+	if err := s.Ready(); err != nil {
+		t.Errorf("Ready: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package handoff
+
+import "os"
+
+// no upgrade signal, the handoff is not available
+var upgradeSignals []os.Signal
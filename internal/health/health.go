@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotReady is returned by Checker.Ready while the application is starting
+// up or draining before shutdown.
+var ErrNotReady = errors.New("service is not ready")
+
+// CheckFunc reports whether a dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker aggregates readiness of the application: an explicit ready flag
+// flipped by the lifecycle code and a list of dependency checks.
+type Checker struct {
+	ready  atomic.Bool
+	mu     sync.RWMutex
+	checks []check
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddCheck registers a dependency check run on every readiness probe.
+func (c *Checker) AddCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// SetReady flips the ready flag. It is set once servers are listening and
+// cleared at the start of graceful shutdown so that traffic drains.
+func (c *Checker) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+// Ready returns nil if the application accepts traffic, otherwise the first
+// failing reason.
+func (c *Checker) Ready(ctx context.Context) error {
+	if !c.ready.Load() {
+		return ErrNotReady
+	}
+
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	for _, ch := range checks {
+		if err := ch.fn(ctx); err != nil {
+			return fmt.Errorf("%s: %w", ch.name, err)
+		}
+	}
+	return nil
+}
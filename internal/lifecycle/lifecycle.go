@@ -0,0 +1,232 @@
+// Package lifecycle starts the parts of the application in order and stops
+// them in reverse order on a single shutdown signal.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Component is a part of the application. All functions are optional.
+type Component struct {
+	Name string
+	// Start prepares the component, e.g. opens its listener. It must not
+	// block; an error aborts the startup.
+	Start func(ctx context.Context) error
+	// Run does the work of the component until Stop is called. Returning
+	// before shutdown, with or without an error, shuts the application down.
+	Run func() error
+	// Stop makes Run return and releases resources. It should give up when
+	// ctx is done.
+	Stop func(ctx context.Context) error
+}
+
+type Options struct {
+	// Deadline of each stop stage, 15s if zero
+	StopTimeout time.Duration
+	// Signals starting the shutdown, SIGINT and SIGTERM if empty. A second
+	// signal during shutdown kills the process.
+	Signals []os.Signal
+}
+
+// Manager owns the components. Components added together with Add form a
+// stage: they start in order and stop concurrently. Stages stop in reverse
+// order, so whatever is added first, like the store, is closed last.
+type Manager struct {
+	opts   Options
+	log    *slog.Logger
+	stages [][]Component
+
+	mu      sync.Mutex
+	running map[string]int // Run functions by component name
+
+	stopping chan struct{}
+	failOnce sync.Once
+	failed   chan struct{}
+	failErr  error
+}
+
+func New(opts Options, logger *slog.Logger) *Manager {
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 15 * time.Second
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return &Manager{
+		opts:     opts,
+		log:      logger,
+		running:  make(map[string]int),
+		stopping: make(chan struct{}),
+		failed:   make(chan struct{}),
+	}
+}
+
+// Add appends a stage of components.
+func (m *Manager) Add(components ...Component) {
+	m.stages = append(m.stages, components)
+}
+
+// Run starts all components and blocks until a shutdown signal arrives,
+// ctx is done or a component fails. It then stops the started stages, waits
+// up to the stop timeout for their Run functions to return and returns the
+// errors which caused or happened during the shutdown.
+func (m *Manager) Run(ctx context.Context) error {
+	sigCtx, stopSignals := signal.NotifyContext(ctx, m.opts.Signals...)
+	defer stopSignals()
+
+	var running sync.WaitGroup
+	started := 0
+	var errs []error
+	for _, stage := range m.stages {
+		if err := m.start(sigCtx, stage, &running); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		started++
+	}
+
+	if len(errs) == 0 {
+		select {
+		case <-sigCtx.Done():
+			m.log.Info("Shutting down")
+		case <-m.failed:
+			m.log.Error("Component failed, shutting down", slog.Any("error", m.failErr))
+			errs = append(errs, m.failErr)
+		}
+	}
+	// a second signal kills the process right away
+	stopSignals()
+	close(m.stopping)
+
+	for i := started - 1; i >= 0; i-- {
+		errs = append(errs, m.stop(m.stages[i]))
+	}
+	if err := m.wait(&running); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// wait waits for the Run functions to return within the stop timeout.
+// Components which ignored their Stop are left running and reported.
+func (m *Manager) wait(running *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(m.opts.StopTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		m.log.Info("Shutdown complete")
+		return nil
+	case <-timer.C:
+	}
+
+	names := m.stillRunning()
+	m.log.Error("Shutdown incomplete, components still running", slog.Any("components", names))
+	return fmt.Errorf("components still running after %s: %s", m.opts.StopTimeout, strings.Join(names, ", "))
+}
+
+func (m *Manager) stillRunning() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name, n := range m.running {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *Manager) start(ctx context.Context, stage []Component, running *sync.WaitGroup) error {
+	for i, c := range stage {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				// the earlier components of the stage are running already
+				m.stop(stage[:i])
+				return fmt.Errorf("start %s: %w", c.Name, err)
+			}
+		}
+		if c.Run != nil {
+			running.Add(1)
+			m.track(c.Name, 1)
+			go func() {
+				defer running.Done()
+				err := c.Run()
+				m.track(c.Name, -1)
+				select {
+				case <-m.stopping:
+					if err != nil {
+						m.log.Error("Component failed during shutdown", slog.String("component", c.Name), slog.Any("error", err))
+					}
+					return
+				default:
+				}
+				if err == nil {
+					err = errors.New("stopped unexpectedly")
+				}
+				m.fail(fmt.Errorf("%s: %w", c.Name, err))
+			}()
+		}
+		m.log.Debug("Component started", slog.String("component", c.Name))
+	}
+	return nil
+}
+
+// stop stops the components of a stage concurrently within the stop
+// timeout.
+func (m *Manager) stop(stage []Component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.StopTimeout)
+	defer cancel()
+
+	errs := make([]error, len(stage))
+	var wg sync.WaitGroup
+	for i, c := range stage {
+		if c.Stop == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			if err := c.Stop(ctx); err != nil {
+				errs[i] = fmt.Errorf("stop %s: %w", c.Name, err)
+				m.log.Error("Component stop failed", slog.String("component", c.Name), slog.Any("error", err))
+				return
+			}
+			m.log.Info("Component stopped", slog.String("component", c.Name), slog.Duration("duration", time.Since(start)))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (m *Manager) track(name string, delta int) {
+	m.mu.Lock()
+	m.running[name] += delta
+	m.mu.Unlock()
+}
+
+// fail starts the shutdown because of a component. Only the first failure
+// is kept.
+func (m *Manager) fail(err error) {
+	m.failOnce.Do(func() {
+		m.failErr = err
+		close(m.failed)
+	})
+}
@@ -0,0 +1,32 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+func TestRunGivesUpOnStuckComponents(t *testing.T) {
+	m := New(Options{StopTimeout: 50 * time.Millisecond}, logging.Discard())
+	stuck := make(chan struct{})
+	defer close(stuck)
+	m.Add(Component{
+		Name: "stuck",
+		Run:  func() error { <-stuck; return nil },
+		Stop: func(context.Context) error { return nil },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := m.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("err = %v, want the stuck component", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+const (
+	// RequestIDHeader is the HTTP header used to pass request IDs in and out.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDMetadataKey is the gRPC metadata key used to pass request IDs.
+	RequestIDMetadataKey = "x-request-id"
+
+	maxRequestIDLen = 128
+)
+
+type requestIDKey struct{}
+
+// New builds the application logger writing to w.
+// format is either "text" or "json". Every record logged with a context
+// carrying a request ID gets a request_id attribute.
+func New(w io.Writer, level slog.Leveler, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported log format %q: use 'text' or 'json'", format)
+	}
+
+	return slog.New(&contextHandler{Handler: h}), nil
+}
+
+// ParseLevel converts level names (debug, info, warn, error) to slog levels.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("unsupported log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+// Discard returns a logger that drops everything. Handy as a default.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// WithRequestID stores the request ID in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random 128-bit request ID.
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// SanitizeRequestID returns id if it is safe to propagate (printable ASCII,
+// reasonable length) and an empty string otherwise.
+func SanitizeRequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLen {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return ""
+		}
+	}
+	return id
+}
+
+// RedactURL strips query string, fragment and user info from a URL so that
+// tokens passed in links do not end up in logs.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if i := strings.IndexAny(raw, "?#"); i >= 0 {
+			return raw[:i] + "?REDACTED"
+		}
+		return raw
+	}
+
+	redacted := u.RawQuery != "" || u.Fragment != "" || u.User != nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	if redacted {
+		return u.String() + "?REDACTED"
+	}
+	return u.String()
+}
+
+// contextHandler adds values carried by the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,174 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	shortContent = "https://sho.rt/abc1234"
+	// needs version 4 or above, 33+ modules
+	longContent = "https://sho.rt/" + "abcdefghij0123456789abcdefghij0123456789abcdefghij"
+)
+
+func bitmap(t *testing.T, content, level string) [][]bool {
+	t.Helper()
+	rl, err := recoveryLevel(level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := goqrcode.New(content, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code.DisableBorder = true
+	return code.Bitmap()
+}
+
+// TestEncodePNG checks every pixel: modules are squares of the same whole
+// number of pixels, centred, with the quiet zone and padding in the
+// background colour.
+func TestEncodePNG(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		level   string
+		margin  int
+	}{
+		{"min size and margin", shortContent, MinSize, "L", 0},
+		{"default", shortContent, DefaultSize, "M", DefaultMargin},
+		{"uneven fit", longContent, 100, "M", 1},
+		{"max size and margin", longContent, MaxSize, "H", MaxMargin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Size, opts.Level, opts.Margin = tt.size, tt.level, tt.margin
+			opts.Foreground = color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+			data, err := Encode(tt.content, opts)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if img.Bounds() != image.Rect(0, 0, tt.size, tt.size) {
+				t.Fatalf("bounds = %v, want %dx%d", img.Bounds(), tt.size, tt.size)
+			}
+
+			modules := bitmap(t, tt.content, tt.level)
+			total := len(modules) + 2*tt.margin
+			scale := tt.size / total
+			offset := (tt.size-scale*total)/2 + tt.margin*scale
+			for y := 0; y < tt.size; y++ {
+				for x := 0; x < tt.size; x++ {
+					mx, my := x-offset, y-offset
+					dark := mx >= 0 && my >= 0 && mx < len(modules)*scale && my < len(modules)*scale &&
+						modules[my/scale][mx/scale]
+					want := color.Color(opts.Background)
+					if dark {
+						want = opts.Foreground
+					}
+					if !sameColor(img.At(x, y), want) {
+						t.Fatalf("pixel (%d, %d) = %v, want %v (scale %d, offset %d)", x, y, img.At(x, y), want, scale, offset)
+					}
+				}
+			}
+		})
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func TestEncodePNGTooSmall(t *testing.T) {
+	if n := len(bitmap(t, longContent, "H")); n+2*MaxMargin <= MinSize {
+		t.Fatalf("test content has only %d modules", n)
+	}
+	opts := DefaultOptions()
+	opts.Size, opts.Level, opts.Margin = MinSize, "H", MaxMargin
+
+	if _, err := Encode(longContent, opts); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Encode = %v, want ErrInvalidOptions", err)
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format, opts.Size, opts.Margin = FormatSVG, MinSize, MaxMargin
+	opts.Background.A = 0
+
+	data, err := Encode(longContent, opts)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	total := len(bitmap(t, longContent, opts.Level)) + 2*MaxMargin
+	svg := string(data)
+	for _, want := range []string{
+		`width="64" height="64"`,
+		fmt.Sprintf(`viewBox="0 0 %d %d"`, total, total),
+		`fill="#ffffff" fill-opacity="0.000"`,
+		`M16 16h7v1h-7z`, // top row of the finder pattern, after the margin
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %q:\n%s", want, svg)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := DefaultOptions()
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("default options: %v", err)
+	}
+	tests := []func(*Options){
+		func(o *Options) { o.Format = "gif" },
+		func(o *Options) { o.Size = MinSize - 1 },
+		func(o *Options) { o.Size = MaxSize + 1 },
+		func(o *Options) { o.Margin = -1 },
+		func(o *Options) { o.Margin = MaxMargin + 1 },
+		func(o *Options) { o.Level = "X" },
+	}
+	for i, change := range tests {
+		opts := DefaultOptions()
+		change(&opts)
+		if err := opts.Validate(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("case %d: Validate(%+v) = %v, want ErrInvalidOptions", i, opts, err)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{"#102030", color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}},
+		{"10203040", color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "#12345", "zzzzzz", "#1234567"} {
+		if _, err := ParseColor(in); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("ParseColor(%q) = %v, want ErrInvalidOptions", in, err)
+		}
+	}
+}
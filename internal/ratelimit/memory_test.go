@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose clock only moves with advance.
+func newTestLimiter(limit Limit) (*MemoryLimiter, func(time.Duration)) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := NewMemoryLimiter(limit)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func allow(t *testing.T, l *MemoryLimiter, key string) (bool, time.Duration) {
+	t.Helper()
+	ok, retryAfter, err := l.Allow(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Allow(%q): %v", key, err)
+	}
+	return ok, retryAfter
+}
+
+func TestMemoryLimiterBurst(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 3})
+	for i := range 3 {
+		if ok, _ := allow(t, l, "ip:a"); !ok {
+			t.Fatalf("request %d of the burst rejected", i+1)
+		}
+	}
+	ok, retryAfter := allow(t, l, "ip:a")
+	if ok || retryAfter != time.Second {
+		t.Errorf("over the burst: allowed %t, retry after %v; want rejected, 1s", ok, retryAfter)
+	}
+	// rejected requests take no token
+	if _, again := allow(t, l, "ip:a"); again != time.Second {
+		t.Errorf("retry after = %v after a rejection, want 1s", again)
+	}
+	// other clients have their own buckets
+	if ok, _ := allow(t, l, "ip:b"); !ok {
+		t.Error("another client rejected")
+	}
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	l, advance := newTestLimiter(Limit{Rate: 2, Burst: 2})
+	allow(t, l, "ip:a")
+	allow(t, l, "ip:a")
+
+	advance(250 * time.Millisecond)
+	ok, retryAfter := allow(t, l, "ip:a")
+	if ok || retryAfter != 250*time.Millisecond {
+		t.Errorf("half a token: allowed %t, retry after %v; want rejected, 250ms", ok, retryAfter)
+	}
+	advance(retryAfter)
+	if ok, _ := allow(t, l, "ip:a"); !ok {
+		t.Error("rejected after the Retry-After")
+	}
+
+	// refilling stops at the burst
+	advance(time.Hour)
+	for range 2 {
+		if ok, _ := allow(t, l, "ip:a"); !ok {
+			t.Fatal("refilled burst rejected")
+		}
+	}
+	if ok, _ := allow(t, l, "ip:a"); ok {
+		t.Error("allowed more than the burst after a long pause")
+	}
+}
+
+func TestMemoryLimiterSetLimit(t *testing.T) {
+	l, advance := newTestLimiter(Limit{Rate: 1, Burst: 5})
+	allow(t, l, "ip:a")
+
+	// the bucket keeps its tokens, capped at the new burst
+	l.SetLimit(Limit{Rate: 1, Burst: 2})
+	for range 2 {
+		if ok, _ := allow(t, l, "ip:a"); !ok {
+			t.Fatal("rejected within the new burst")
+		}
+	}
+	if ok, _ := allow(t, l, "ip:a"); ok {
+		t.Error("allowed over the new burst")
+	}
+
+	l.SetLimit(Limit{})
+	for range 10 {
+		if ok, retryAfter := allow(t, l, "ip:a"); !ok || retryAfter != 0 {
+			t.Fatal("unlimited limiter rejected a request")
+		}
+	}
+
+	// full buckets are swept, partly used ones are kept
+	l.SetLimit(Limit{Rate: 1, Burst: 60})
+	allow(t, l, "ip:b")
+	advance(sweepInterval / 2)
+	for range 60 {
+		allow(t, l, "ip:c")
+	}
+	advance(sweepInterval / 2)
+	allow(t, l, "ip:d")
+	if _, ok := l.buckets["ip:b"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := l.buckets["ip:c"]; !ok {
+		t.Error("partly used bucket was swept")
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const cleanupInterval = 5 * time.Minute
+
+// PostgresLimiter keeps token buckets in a shared table so that all
+// instances of the service enforce a common limit.
+type PostgresLimiter struct {
+	pool  *pgxpool.Pool
+	class Class
+	log   *slog.Logger
+
+	mu    sync.Mutex
+	limit Limit
+}
+
+// NewPostgresLimiter creates the buckets table if needed and starts a
+// background cleanup of idle buckets which stops when ctx is done.
+func NewPostgresLimiter(ctx context.Context, pool *pgxpool.Pool, class Class, limit Limit, logger *slog.Logger) (*PostgresLimiter, error) {
+	l := &PostgresLimiter{
+		pool:  pool,
+		class: class,
+		limit: limit,
+		log:   logger,
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+			bucket     TEXT PRIMARY KEY,
+			tokens     DOUBLE PRECISION NOT NULL,
+			allowed    BOOLEAN NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
+	`
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create rate limit table: %w", err)
+	}
+
+	go l.cleanup(ctx)
+	return l, nil
+}
+
+func (l *PostgresLimiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+func (l *PostgresLimiter) currentLimit() Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	limit := l.currentLimit()
+	if limit.Unlimited() {
+		return true, 0, nil
+	}
+
+	// The row lock taken by ON CONFLICT DO UPDATE serializes concurrent
+	// requests for the same bucket across all instances.
+	query := `
+	INSERT INTO rate_limit_buckets AS b (bucket, tokens, allowed, updated_at)
+	VALUES ($1, $2::float8 - 1, TRUE, now())
+	ON CONFLICT (bucket) DO UPDATE SET
+		allowed = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) >= 1,
+		tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8)
+			- CASE WHEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) >= 1 THEN 1 ELSE 0 END,
+		updated_at = now()
+	RETURNING allowed, tokens`
+
+	var (
+		allowed bool
+		tokens  float64
+	)
+	bucket := string(l.class) + ":" + key
+	err := l.pool.QueryRow(ctx, query, bucket, float64(limit.Burst), limit.Rate).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	if allowed {
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+func (l *PostgresLimiter) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		limit := l.currentLimit()
+		if limit.Unlimited() {
+			continue
+		}
+		// a bucket idle for longer than a full refill is equivalent to a missing one
+		idle := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+
+		_, err := l.pool.Exec(ctx,
+			`DELETE FROM rate_limit_buckets WHERE bucket LIKE $1 AND updated_at < now() - $2::interval`,
+			string(l.class)+":%", fmt.Sprintf("%d seconds", int(idle.Seconds())+1))
+		if err != nil && ctx.Err() == nil {
+			l.log.Warn("failed to clean up rate limit buckets", slog.Any("error", err))
+		}
+	}
+}
+
+var _ Limiter = (*PostgresLimiter)(nil)
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Class groups operations that share a limit.
+type Class string
+
+const (
+	ClassCreate   Class = "create"
+	ClassLookup   Class = "lookup"
+	ClassRedirect Class = "redirect"
+	// Password attempts for protected links, keyed by client and link
+	ClassPassword Class = "password"
+)
+
+// Limit describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second. The zero Limit means "unlimited".
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s burst %d", l.Rate, l.Burst)
+}
+
+// ParseLimit parses "N/period" (e.g. "60/1m", "10/s") into a Limit that allows
+// N requests per period with a burst of N. Empty string or "0" disables
+// limiting.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	countStr, periodStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected N/period", s)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit count %q", countStr)
+	}
+
+	// allow "s", "m", "h" without a leading number
+	if periodStr != "" && (periodStr[0] < '0' || periodStr[0] > '9') {
+		periodStr = "1" + periodStr
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit period %q", periodStr)
+	}
+
+	if count == 0 {
+		return Limit{}, nil
+	}
+	return Limit{Rate: float64(count) / period.Seconds(), Burst: count}, nil
+}
+
+// Limiter decides whether a request identified by key may proceed.
+// When it may not, retryAfter tells the client when to come back.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// Adjustable limiters take a new limit at runtime, e.g. on a configuration
+// reload. Buckets keep their tokens, capped at the new burst.
+type Adjustable interface {
+	SetLimit(limit Limit)
+}
+
+type clientIDKey struct{}
+
+// WithClientID stores an authenticated client identity (e.g. an API key ID)
+// in ctx. Limits are then keyed by this identity instead of the client IP.
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+// ClientKey returns the bucket key for a request: the authenticated client
+// identity from ctx if any, otherwise the client IP.
+func ClientKey(ctx context.Context, ip string) string {
+	if id, ok := ctx.Value(clientIDKey{}).(string); ok && id != "" {
+		return "key:" + id
+	}
+	return "ip:" + ip
+}
+
+// RetryAfterSeconds rounds d up to whole seconds, as used in the
+// Retry-After header.
+func RetryAfterSeconds(d time.Duration) int {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"", Limit{}},
+		{"0", Limit{}},
+		{" 0/m ", Limit{}},
+		{"10/s", Limit{Rate: 10, Burst: 10}},
+		{"60/1m", Limit{Rate: 1, Burst: 60}},
+		{"30/m", Limit{Rate: 0.5, Burst: 30}},
+		{"3600/h", Limit{Rate: 1, Burst: 3600}},
+		{"5/500ms", Limit{Rate: 10, Burst: 5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"10", "ten/s", "-1/s", "10/", "10/0s", "10/-1m", "10/fortnight", "1.5/s"} {
+		if got, err := ParseLimit(in); err == nil {
+			t.Errorf("ParseLimit(%q) = %+v, want an error", in, got)
+		}
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	if s := (Limit{}).String(); s != "unlimited" {
+		t.Errorf("zero limit = %q", s)
+	}
+	if s := (Limit{Rate: 0.5, Burst: 30}).String(); s != "0.5/s burst 30" {
+		t.Errorf("limit = %q", s)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	ctx := context.Background()
+	if key := ClientKey(ctx, "192.0.2.1"); key != "ip:192.0.2.1" {
+		t.Errorf("anonymous key = %q", key)
+	}
+	if key := ClientKey(WithClientID(ctx, "k1"), "192.0.2.1"); key != "key:k1" {
+		t.Errorf("authenticated key = %q", key)
+	}
+	if key := ClientKey(WithClientID(ctx, ""), "192.0.2.1"); key != "ip:192.0.2.1" {
+		t.Errorf("empty client ID key = %q", key)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for d, want := range map[time.Duration]int{
+		0:                       1,
+		time.Millisecond:        1,
+		time.Second:             1,
+		time.Second + 1:         2,
+		90 * time.Second:        90,
+		1500 * time.Millisecond: 2,
+	} {
+		if got := RetryAfterSeconds(d); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
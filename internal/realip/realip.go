@@ -0,0 +1,130 @@
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+// Resolver finds the address of the client that originated a request.
+// Forwarding headers are only honoured when the direct peer is one of the
+// trusted proxies, otherwise anyone could spoof their address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver parses a comma separated list of trusted proxy addresses or
+// CIDR ranges, e.g. "10.0.0.0/8,127.0.0.1". An empty list trusts nobody.
+func NewResolver(trustedProxies string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, item := range strings.Split(trustedProxies, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy range %q: %w", item, err)
+			}
+			r.trusted = append(r.trusted, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy address %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return r, nil
+}
+
+// IsTrusted reports whether addr belongs to a trusted proxy.
+func (r *Resolver) IsTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range r.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type clientIPKey struct{}
+
+// WithClientIP stores the resolved client IP of a request in ctx.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the client IP stored by WithClientIP.
+func ClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok
+}
+
+// FromRequest returns the client IP of an HTTP request, the one stored in
+// its context if any.
+func (r *Resolver) FromRequest(req *http.Request) string {
+	if ip, ok := ClientIP(req.Context()); ok {
+		return ip
+	}
+	return r.Resolve(req.RemoteAddr, req.Header.Values(HeaderForwardedFor), req.Header.Get(HeaderRealIP))
+}
+
+// Resolve returns the client IP given the address of the direct peer and the
+// values of the forwarding headers. X-Forwarded-For is walked from right to
+// left skipping trusted proxies; X-Real-IP is used when it is absent.
+func (r *Resolver) Resolve(remoteAddr string, forwardedFor []string, realIP string) string {
+	peer, ok := parseHostAddr(remoteAddr)
+	if !ok {
+		return remoteAddr
+	}
+	if !r.IsTrusted(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	for _, v := range forwardedFor {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHostAddr(strings.TrimSpace(hops[i]))
+		if !ok {
+			break
+		}
+		if i == 0 || !r.IsTrusted(addr) {
+			return addr.String()
+		}
+	}
+
+	if addr, ok := parseHostAddr(strings.TrimSpace(realIP)); ok {
+		return addr.String()
+	}
+	return peer.String()
+}
+
+func parseHostAddr(s string) (netip.Addr, bool) {
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
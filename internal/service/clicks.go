@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+type clickKey struct {
+	domain string
+	code   string
+}
+
+// ClickRecorder counts redirects in memory and periodically writes the
+// accumulated counts to the store, so that redirects never wait for a write.
+type ClickRecorder struct {
+	store    storage.URLStore
+	interval time.Duration
+	log      *slog.Logger
+
+	mu      sync.Mutex
+	pending map[clickKey]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewClickRecorder(store storage.URLStore, flushInterval time.Duration, logger *slog.Logger) *ClickRecorder {
+	return &ClickRecorder{
+		store:    store,
+		interval: flushInterval,
+		log:      logger,
+		pending:  make(map[clickKey]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Record counts one click. domain is the storage domain key.
+func (c *ClickRecorder) Record(domain, code string) {
+	c.mu.Lock()
+	c.pending[clickKey{domain, code}]++
+	c.mu.Unlock()
+}
+
+// Run flushes counts every flush interval until Stop is called.
+func (c *ClickRecorder) Run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush()
+		case <-c.stop:
+			c.Flush()
+			return
+		}
+	}
+}
+
+// Stop makes Run write the remaining counts and waits for it to return.
+func (c *ClickRecorder) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// Flush writes accumulated counts to the store. Counts which fail to be
+// written are kept for the next flush unless the link is gone.
+func (c *ClickRecorder) Flush() {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[clickKey]int64, len(batch))
+	c.mu.Unlock()
+
+	for key, n := range batch {
+		err := c.store.AddClicks(key.domain, key.code, n)
+		if err == nil || errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+
+		c.log.Warn("failed to record clicks", slog.String("code", key.code), slog.Int64("clicks", n), slog.Any("error", err))
+		c.mu.Lock()
+		c.pending[key] += n
+		c.mu.Unlock()
+	}
+}
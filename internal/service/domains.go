@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownDomain = errors.New("unknown short domain")
+
+// Domains is the set of short domains served by this deployment.
+// Links of the default domain are stored under the empty domain, so
+// renaming the default domain keeps existing links working.
+type Domains struct {
+	scheme        string
+	defaultDomain string
+	known         map[string]bool
+}
+
+// NewDomains builds the domain set. scheme ("http" or "https") is used to
+// build public short URLs. Domain names may include a port
+// (e.g. "localhost:8081") and are compared case-insensitively.
+func NewDomains(scheme, defaultDomain string, extra ...string) *Domains {
+	d := &Domains{
+		scheme:        scheme,
+		defaultDomain: canonicalDomain(defaultDomain),
+		known:         make(map[string]bool),
+	}
+	d.known[d.defaultDomain] = true
+	for _, name := range extra {
+		if name = canonicalDomain(name); name != "" {
+			d.known[name] = true
+		}
+	}
+	return d
+}
+
+func (d *Domains) Default() string {
+	return d.defaultDomain
+}
+
+// Resolve returns the canonical name of a known domain. The empty name
+// resolves to the default domain.
+func (d *Domains) Resolve(name string) (string, error) {
+	if name == "" {
+		return d.defaultDomain, nil
+	}
+	name = canonicalDomain(name)
+	if !d.known[name] {
+		return "", ErrUnknownDomain
+	}
+	return name, nil
+}
+
+// ForHost returns the domain serving the given Host header. A host with a
+// port also matches a domain configured without one.
+func (d *Domains) ForHost(host string) (string, bool) {
+	host = canonicalDomain(host)
+	if d.known[host] {
+		return host, true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil && d.known[h] {
+		return h, true
+	}
+	return "", false
+}
+
+// ShortURL returns the public URL of a code in a known domain.
+func (d *Domains) ShortURL(name, code string) (string, error) {
+	name, err := d.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: d.scheme, Host: name, Path: "/" + code}).String(), nil
+}
+
+// storageKey maps a domain name to the value kept in storage.
+func (d *Domains) storageKey(name string) (string, error) {
+	name, err := d.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	if name == d.defaultDomain {
+		return "", nil
+	}
+	return name, nil
+}
+
+// nameForKey is the inverse of storageKey.
+func (d *Domains) nameForKey(key string) string {
+	if key == "" {
+		return d.defaultDomain
+	}
+	return key
+}
+
+// keysContaining returns the storage keys of the short domains whose name
+// contains substr.
+func (d *Domains) keysContaining(substr string) []string {
+	substr = strings.ToLower(strings.TrimSpace(substr))
+	var keys []string
+	for name := range d.known {
+		if strings.Contains(name, substr) {
+			key, _ := d.storageKey(name)
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func canonicalDomain(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListOptions filters and pages ListLinks. Zero fields do not filter.
+type ListOptions struct {
+	// Case-insensitive substring of the name of the short domain the links
+	// belong to. The host of the original URL is not searched.
+	ShortDomain string
+	// API key ID which created the links, the caller's key if empty.
+	// Only admin keys may list other owners' links, or all links with an
+	// empty Owner.
+	Owner string
+	Tag   string
+	// "created" (default) or "clicks"; newest or most clicked first
+	Sort string
+	// Page size, DefaultListLimit if zero, at most MaxListLimit
+	Limit int
+	// NextCursor of the previous page
+	Cursor string
+}
+
+type LinkPage struct {
+	Links []LinkInfo
+	// Pass as ListOptions.Cursor to get the next page; empty on the last page
+	NextCursor string
+}
+
+// ListLinks returns a page of links of the caller's API key. Destinations
+// of password protected links are only shown to their owner.
+func (us *UrlService) ListLinks(ctx context.Context, opts ListOptions) (LinkPage, error) {
+	caller, ok := auth.KeyFromContext(ctx)
+	if !ok {
+		return LinkPage{}, auth.ErrUnauthenticated
+	}
+	switch {
+	case caller.Admin:
+	case opts.Owner == "":
+		opts.Owner = caller.ID
+	case opts.Owner != caller.ID:
+		return LinkPage{}, fmt.Errorf("%w: only admin keys list links of other owners", auth.ErrForbidden)
+	}
+
+	query := storage.ListQuery{
+		Owner:  opts.Owner,
+		Tag:    normalizeTag(opts.Tag),
+		Sort:   storage.SortOrder(opts.Sort),
+		Limit:  opts.Limit,
+		Cursor: opts.Cursor,
+	}
+	switch query.Sort {
+	case "", storage.SortByCreated, storage.SortByClicks:
+	default:
+		return LinkPage{}, fmt.Errorf("%w: unsupported sort %q", ErrInvalidListOptions, opts.Sort)
+	}
+	switch {
+	case query.Limit < 0:
+		return LinkPage{}, fmt.Errorf("%w: negative limit", ErrInvalidListOptions)
+	case query.Limit == 0:
+		query.Limit = DefaultListLimit
+	case query.Limit > MaxListLimit:
+		query.Limit = MaxListLimit
+	}
+	if opts.ShortDomain != "" {
+		query.Domains = us.domains.keysContaining(opts.ShortDomain)
+		if len(query.Domains) == 0 {
+			return LinkPage{}, nil
+		}
+	}
+
+	page, err := us.store.ListLinks(query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return LinkPage{}, fmt.Errorf("%w: %w", ErrInvalidListOptions, err)
+		}
+		return LinkPage{}, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	result := LinkPage{Links: make([]LinkInfo, 0, len(page.Links)), NextCursor: page.NextCursor}
+	for _, link := range page.Links {
+		info := us.linkInfo(link)
+		if info.Protected && (link.Owner == "" || link.Owner != caller.ID) {
+			info.OriginURL = ""
+		}
+		result.Links = append(result.Links, info)
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+var ErrInvalidMetadata = errors.New("invalid link metadata")
+
+const (
+	maxTitleLen       = 200
+	maxDescriptionLen = 2000
+	maxTags           = 20
+	maxTagLen         = 64
+)
+
+// LinkUpdate changes the metadata of a link. Nil fields are left as is.
+type LinkUpdate struct {
+	Title       *string
+	Description *string
+	Tags        *[]string
+}
+
+// UpdateLink changes link metadata. Only the API key which created the
+// link may update it.
+func (us *UrlService) UpdateLink(ctx context.Context, domain, short string, update LinkUpdate) (LinkInfo, error) {
+	key, ok := auth.KeyFromContext(ctx)
+	if !ok {
+		return LinkInfo{}, auth.ErrUnauthenticated
+	}
+
+	link, err := us.getLink(domain, short)
+	if err != nil {
+		return LinkInfo{}, err
+	}
+	if link.Owner == "" || link.Owner != key.ID {
+		return LinkInfo{}, auth.ErrForbidden
+	}
+
+	meta := link.Metadata
+	if update.Title != nil {
+		meta.Title = *update.Title
+	}
+	if update.Description != nil {
+		meta.Description = *update.Description
+	}
+	if update.Tags != nil {
+		meta.Tags = *update.Tags
+	}
+	if meta, err = normalizeMetadata(meta); err != nil {
+		return LinkInfo{}, err
+	}
+
+	if err := us.store.UpdateMetadata(link.Domain, link.ShortCode, meta); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return LinkInfo{}, err
+		}
+		return LinkInfo{}, fmt.Errorf("failed to update link: %w", err)
+	}
+	link.Metadata = meta
+
+	us.log.InfoContext(ctx, "short url updated", slog.String("domain", us.domains.nameForKey(link.Domain)),
+		slog.String("code", short), slog.String("owner", key.ID))
+	return us.linkInfo(link), nil
+}
+
+// normalizeMetadata trims the fields and lower-cases, deduplicates and
+// sorts the tags.
+func normalizeMetadata(meta storage.Metadata) (storage.Metadata, error) {
+	meta.Title = strings.TrimSpace(meta.Title)
+	meta.Description = strings.TrimSpace(meta.Description)
+	if utf8.RuneCountInString(meta.Title) > maxTitleLen {
+		return meta, fmt.Errorf("%w: title longer than %d characters", ErrInvalidMetadata, maxTitleLen)
+	}
+	if utf8.RuneCountInString(meta.Description) > maxDescriptionLen {
+		return meta, fmt.Errorf("%w: description longer than %d characters", ErrInvalidMetadata, maxDescriptionLen)
+	}
+
+	tags := make([]string, 0, len(meta.Tags))
+	for _, tag := range meta.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return meta, fmt.Errorf("%w: tag longer than %d characters", ErrInvalidMetadata, maxTagLen)
+		}
+		if strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsSpace(r) || unicode.IsControl(r) }) {
+			return meta, fmt.Errorf("%w: tag %q contains commas or whitespace", ErrInvalidMetadata, tag)
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	if len(tags) > maxTags {
+		return meta, fmt.Errorf("%w: more than %d tags", ErrInvalidMetadata, maxTags)
+	}
+	meta.Tags = tags
+	return meta, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
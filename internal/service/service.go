@@ -1,80 +1,401 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/yihleego/base62"
+	"golang.org/x/crypto/bcrypt"
 
-	"github.com/vadyaov/url_shortener/internal/storage"
+	"github.com/vadyaov/url_shortener/internal/auth"
+	"github.com/vadyaov/url_shortener/internal/logging"
 	normalizeurl "github.com/vadyaov/url_shortener/internal/normalize"
+	"github.com/vadyaov/url_shortener/internal/qrcode"
+	"github.com/vadyaov/url_shortener/internal/storage"
 )
 
+// Domain arguments are short domain names; the empty string means the
+// default domain. Unknown domains fail with ErrUnknownDomain.
 type URLShortenerService interface {
-	GetShortUrl(origin string) (string, error)
-	GetOriginUrl(short string) (string, error)
+	GetShortUrl(ctx context.Context, origin string, opts CreateOptions) (string, error)
+	// Fails with ErrPasswordRequired for password protected links
+	GetOriginUrl(ctx context.Context, domain, short string) (string, error)
+	// Resolves a link checking its password, if any
+	ResolveWithPassword(ctx context.Context, domain, short, password string) (LinkInfo, error)
+	// Returns details shown on the preview page.
+	// Fails with ErrPasswordRequired for password protected links
+	GetLinkInfo(ctx context.Context, domain, short string) (LinkInfo, error)
+	// Counts a redirect served for the link
+	RecordClick(ctx context.Context, domain, short string)
+	// Changes link metadata, only allowed for the link owner
+	UpdateLink(ctx context.Context, domain, short string, update LinkUpdate) (LinkInfo, error)
+	DeleteShortUrl(ctx context.Context, domain, short string) error
+
+	// Renders a QR code pointing to the public short URL of an existing code
+	GetQrCode(ctx context.Context, domain, short string, opts qrcode.Options) ([]byte, error)
+
+	// Returns a page of stored links
+	ListLinks(ctx context.Context, opts ListOptions) (LinkPage, error)
+
+	// Returns the domain served for the request Host header
+	DomainForHost(host string) (string, bool)
+}
+
+var (
+	ErrPasswordRequired = errors.New("short URL is password protected")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrPasswordTooLong  = fmt.Errorf("password must be at most %d bytes", maxPasswordLen)
+)
+
+// bcrypt ignores everything past 72 bytes
+const maxPasswordLen = 72
+
+// CreateOptions are optional settings of a new short link. Links with a
+// password, an interstitial or metadata always get a fresh code instead of
+// reusing an existing one for the same URL.
+type CreateOptions struct {
+	// Short domain to create the link under, default domain if empty
+	Domain string
+	// Protects the link with a password
+	Password string
+	// Always show the preview page before redirecting
+	Interstitial bool
+	storage.Metadata
+}
+
+// LinkInfo describes a link on the preview page and in listings.
+type LinkInfo struct {
+	Domain    string
+	ShortCode string
+	ShortURL  string
+	OriginURL string
+	storage.Metadata
+	// API key ID which created the link
+	Owner     string
+	CreatedAt time.Time
+	Clicks    int64
+	Protected bool
+	// Redirects go through the preview page first, either because the
+	// link asks for it or because its destination is external.
+	Interstitial bool
+}
+
+type Options struct {
+	// Counts redirects; nil disables click counting
+	Clicks *ClickRecorder
+	// Show the preview page before redirecting to destinations outside
+	// InternalHosts
+	InterstitialExternal bool
+	// Destination hosts (and their subdomains) considered internal
+	InternalHosts []string
 }
 
 type UrlService struct {
-	store storage.URLStore
+	store   storage.URLStore
+	domains *Domains
+	opts    Options
+	log     *slog.Logger
+
+	// guards the interstitial fields of opts, they change on reload
+	policyMu sync.RWMutex
+}
+
+func NewUrlService(store storage.URLStore, domains *Domains, opts Options, logger *slog.Logger) *UrlService {
+	return &UrlService{store: store, domains: domains, opts: opts, log: logger}
 }
 
-func NewUrlService(store storage.URLStore) *UrlService {
-	return &UrlService{store: store}
+// SetInterstitialPolicy replaces InterstitialExternal and InternalHosts of
+// the options.
+func (us *UrlService) SetInterstitialPolicy(external bool, internalHosts []string) {
+	us.policyMu.Lock()
+	defer us.policyMu.Unlock()
+	us.opts.InterstitialExternal = external
+	us.opts.InternalHosts = internalHosts
 }
 
-func (us *UrlService) GetShortUrl(origin string) (string, error) {
+func (us *UrlService) DomainForHost(host string) (string, bool) {
+	return us.domains.ForHost(host)
+}
+
+func (us *UrlService) GetShortUrl(ctx context.Context, origin string, opts CreateOptions) (string, error) {
+	domain, err := us.domains.storageKey(opts.Domain)
+	if err != nil {
+		return "", err
+	}
+
 	origin_norm, err := normalizeurl.Normalize(origin)
 	if err != nil {
 		return "", err
 	}
 
-	existingShort, err := us.store.GetShortURL(origin_norm)
-	if err == nil {
-		fmt.Println("This url is already exists in map, returning existing short url: ", existingShort)
-		return existingShort, nil
+	var passwordHash string
+	if opts.Password != "" {
+		if len(opts.Password) > maxPasswordLen {
+			return "", ErrPasswordTooLong
+		}
+		h, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwordHash = string(h)
 	}
 
-	fmt.Println("Creating new short url for ", origin)
+	meta, err := normalizeMetadata(opts.Metadata)
+	if err != nil {
+		return "", err
+	}
+	dedicated := meta.Title != "" || meta.Description != "" || len(meta.Tags) > 0
 
-	hash := sha256.Sum256([]byte(origin))
+	hashInput := []byte(origin)
+	if opts.Password != "" || opts.Interstitial || dedicated {
+		// random salt so that the code does not collide with the plain link
+		var salt [16]byte
+		if _, err := rand.Read(salt[:]); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		hashInput = append(hashInput, salt[:]...)
+	} else {
+		existingShort, err := us.store.GetShortURL(domain, origin_norm)
+		if err == nil {
+			us.log.DebugContext(ctx, "url already shortened, returning existing code",
+				slog.String("url", logging.RedactURL(origin_norm)), slog.String("code", existingShort))
+			return existingShort, nil
+		}
+	}
+
+	us.log.DebugContext(ctx, "creating new short url", slog.String("url", logging.RedactURL(origin)),
+		slog.Bool("protected", passwordHash != ""), slog.Bool("interstitial", opts.Interstitial))
+
+	var owner string
+	if key, ok := auth.KeyFromContext(ctx); ok {
+		owner = key.ID
+	}
+
+	hash := sha256.Sum256(hashInput)
 	// try several lengths if collisions occur
 	for length := 7; length <= 10; length++ {
 		encoded := base62.StdEncoding.EncodeToString(hash[:])[:length]
 
 		// try to save. if shortCode is already exist
 		// then SaveURL should produce an error --> go to another cycle iter
-		errSave := us.store.SaveURL(origin, encoded)
+		errSave := us.store.SaveURL(storage.Link{
+			Domain:       domain,
+			ShortCode:    encoded,
+			OriginURL:    origin,
+			Owner:        owner,
+			PasswordHash: passwordHash,
+			Interstitial: opts.Interstitial,
+			Dedicated:    dedicated,
+			Metadata:     meta,
+		})
 		if errSave == nil {
+			us.log.InfoContext(ctx, "short url created",
+				slog.String("domain", us.domains.nameForKey(domain)), slog.String("code", encoded))
 			return encoded, nil
 		}
 
 		if errors.Is(errSave, storage.ErrDuplicateShortCode) {
-			fmt.Printf("Collision detected for short code '%s' with length %d, trying longer.\n", encoded, length)
+			us.log.WarnContext(ctx, "short code collision, trying longer code",
+				slog.String("code", encoded), slog.Int("length", length))
 
 			if length == 10 {
-				return "", fmt.Errorf("failed to genereate unique short code for '%s' after multiple attempts: %w", origin, errSave)
+				return "", fmt.Errorf("failed to genereate unique short code for '%s' after multiple attempts: %w", logging.RedactURL(origin), errSave)
 			}
 
 			continue
 		}
 
+		// a concurrent request shortened the same URL with a longer code
+		if errors.Is(errSave, storage.ErrDuplicateOriginURL) {
+			if existingShort, err := us.store.GetShortURL(domain, origin); err == nil {
+				return existingShort, nil
+			}
+		}
+
 		return "", fmt.Errorf("failed to save URL: %w", errSave)
 	}
 	return "", errors.New("could not generate unique short URL")
 }
 
-func (us *UrlService) GetOriginUrl(short string) (string, error) {
-	origin, err := us.store.GetOriginURL(short)
+func (us *UrlService) GetOriginUrl(ctx context.Context, domain, short string) (string, error) {
+	link, err := us.getLink(domain, short)
+	if err != nil {
+		return "", err
+	}
+
+	if link.PasswordHash != "" {
+		return "", ErrPasswordRequired
+	}
+	return link.OriginURL, nil
+}
+
+// ResolveWithPassword returns the link if password matches the one the
+// link was created with. Public links resolve with any password.
+func (us *UrlService) ResolveWithPassword(ctx context.Context, domain, short, password string) (LinkInfo, error) {
+	link, err := us.getLink(domain, short)
+	if err != nil {
+		return LinkInfo{}, err
+	}
 
+	if link.PasswordHash == "" {
+		return us.linkInfo(link), nil
+	}
+	if password == "" {
+		return LinkInfo{}, ErrPasswordRequired
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		us.log.InfoContext(ctx, "invalid password for protected link", slog.String("code", short))
+		return LinkInfo{}, ErrInvalidPassword
+	}
+	return us.linkInfo(link), nil
+}
+
+func (us *UrlService) GetLinkInfo(ctx context.Context, domain, short string) (LinkInfo, error) {
+	link, err := us.getLink(domain, short)
 	if err != nil {
+		return LinkInfo{}, err
+	}
+	if link.PasswordHash != "" {
+		return LinkInfo{}, ErrPasswordRequired
+	}
+
+	return us.linkInfo(link), nil
+}
+
+func (us *UrlService) linkInfo(link storage.Link) LinkInfo {
+	name := us.domains.nameForKey(link.Domain)
+	shortURL, _ := us.domains.ShortURL(name, link.ShortCode)
+	return LinkInfo{
+		Domain:       name,
+		ShortCode:    link.ShortCode,
+		ShortURL:     shortURL,
+		OriginURL:    link.OriginURL,
+		Owner:        link.Owner,
+		CreatedAt:    link.CreatedAt,
+		Metadata:     link.Metadata,
+		Clicks:       link.Clicks,
+		Protected:    link.PasswordHash != "",
+		Interstitial: link.Interstitial || us.externalInterstitial(link.OriginURL),
+	}
+}
+
+func (us *UrlService) RecordClick(ctx context.Context, domain, short string) {
+	if us.opts.Clicks == nil {
+		return
+	}
+	if domain, err := us.domains.storageKey(domain); err == nil {
+		us.opts.Clicks.Record(domain, short)
+	}
+}
+
+// externalInterstitial reports whether the destination needs the preview
+// page because it is external.
+func (us *UrlService) externalInterstitial(origin string) bool {
+	us.policyMu.RLock()
+	defer us.policyMu.RUnlock()
+	return us.opts.InterstitialExternal && !us.isInternal(origin)
+}
+
+// isInternal reports whether the destination host is one of InternalHosts
+// or their subdomain. The caller holds policyMu.
+func (us *UrlService) isInternal(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, internal := range us.opts.InternalHosts {
+		internal = strings.ToLower(strings.TrimSpace(internal))
+		if internal != "" && (host == internal || strings.HasSuffix(host, "."+internal)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (us *UrlService) getLink(domain, short string) (storage.Link, error) {
+	domain, err := us.domains.storageKey(domain)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	link, err := us.store.GetLink(domain, short)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return storage.Link{}, err
+		}
+		return storage.Link{}, fmt.Errorf("failed to get original url: %w", err)
+	}
+	return link, nil
+}
+
+// DeleteShortUrl removes the short code. Only the API key which created
+// the link may delete it.
+func (us *UrlService) DeleteShortUrl(ctx context.Context, domain, short string) error {
+	key, ok := auth.KeyFromContext(ctx)
+	if !ok {
+		return auth.ErrUnauthenticated
+	}
+
+	domain, err := us.domains.storageKey(domain)
+	if err != nil {
+		return err
+	}
+
+	link, err := us.store.GetLink(domain, short)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to get link: %w", err)
+	}
+
+	if link.Owner == "" || link.Owner != key.ID {
+		return auth.ErrForbidden
+	}
+
+	if err := us.store.DeleteURL(domain, short); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			return "", err
+			return err
 		}
-		return "", fmt.Errorf("failed to get original url: %w", err)
+		return fmt.Errorf("failed to delete url: %w", err)
+	}
+
+	us.log.InfoContext(ctx, "short url deleted", slog.String("domain", us.domains.nameForKey(domain)),
+		slog.String("code", short), slog.String("owner", key.ID))
+	return nil
+}
+
+// GetQrCode renders the public short URL of an existing code as a QR code.
+// Returns storage.ErrNotFound for unknown codes.
+func (us *UrlService) GetQrCode(ctx context.Context, domain, short string, opts qrcode.Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// protected links get a QR code too: it points to the password form
+	if _, err := us.getLink(domain, short); err != nil {
+		return nil, err
+	}
+
+	shortURL, err := us.domains.ShortURL(domain, short)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := qrcode.Encode(shortURL, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
 	}
-	return origin, nil
+	return img, nil
 }
 
 var _ URLShortenerService = (*UrlService)(nil)
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid list cursor")
+
+// SortOrder selects the order of ListLinks results. Both orders are
+// descending and break ties by domain and short code.
+type SortOrder string
+
+const (
+	SortByCreated SortOrder = "created"
+	SortByClicks  SortOrder = "clicks"
+)
+
+// ListQuery filters and pages ListLinks. Zero fields do not filter.
+type ListQuery struct {
+	// Restricts results to these domains (storage keys, "" is the default domain)
+	Domains []string
+	Owner   string
+	Tag     string
+	Sort    SortOrder
+	// Maximum number of links in the page, must be positive
+	Limit int
+	// NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// ListPage is one page of ListLinks results. NextCursor is empty on the
+// last page.
+type ListPage struct {
+	Links      []Link
+	NextCursor string
+}
+
+// cursor is the position after the last link of a page. Sorting by
+// clicks pages over live counters, so links whose counters change while
+// paging may be skipped or repeated.
+type cursor struct {
+	Sort      SortOrder `json:"o"`
+	CreatedAt time.Time `json:"t"`
+	Clicks    int64     `json:"c"`
+	Domain    string    `json:"d"`
+	ShortCode string    `json:"s"`
+}
+
+func cursorAfter(sort SortOrder, link Link) string {
+	c := cursor{Sort: sort, Domain: link.Domain, ShortCode: link.ShortCode}
+	if sort == SortByClicks {
+		c.Clicks = link.Clicks
+	} else {
+		c.CreatedAt = link.CreatedAt
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseCursor(sort SortOrder, s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// prepareQuery validates the query and decodes its cursor. An empty sort
+// order defaults to SortByCreated.
+func prepareQuery(query ListQuery) (SortOrder, *cursor, error) {
+	sort := query.Sort
+	switch sort {
+	case "":
+		sort = SortByCreated
+	case SortByCreated, SortByClicks:
+	default:
+		return "", nil, fmt.Errorf("unsupported sort order %q", sort)
+	}
+	if query.Limit <= 0 {
+		return "", nil, errors.New("list limit must be positive")
+	}
+	after, err := parseCursor(sort, query.Cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	return sort, after, nil
+}
+
+// before reports whether link a comes before link b in the sort order.
+func before(sort SortOrder, a, b Link) bool {
+	if sort == SortByClicks {
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	if a.Domain != b.Domain {
+		return a.Domain > b.Domain
+	}
+	return a.ShortCode > b.ShortCode
+}
+
+// afterCursor reports whether the link comes after the cursor position.
+func (c *cursor) afterCursor(link Link) bool {
+	return before(c.Sort, Link{CreatedAt: c.CreatedAt, Clicks: c.Clicks, Domain: c.Domain, ShortCode: c.ShortCode}, link)
+}
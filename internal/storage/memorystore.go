@@ -2,60 +2,225 @@ package storage
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
 )
 
+// domainKey scopes a short code or an original URL to a domain.
+type domainKey struct {
+	domain string
+	value  string
+}
+
 type InMemoryStore struct {
-	mu sync.RWMutex
-	shortToOrig map[string]string
-	origToShort map[string]string
+	mu          sync.RWMutex
+	shortToLink map[domainKey]Link
+	origToShort map[domainKey]string
+	log         *slog.Logger
 }
 
-func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore {
-		shortToOrig: make(map[string]string),
-		origToShort: make(map[string]string),
+func NewInMemoryStore(logger *slog.Logger) *InMemoryStore {
+	return &InMemoryStore{
+		shortToLink: make(map[domainKey]Link),
+		origToShort: make(map[domainKey]string),
+		log:         logger,
 	}
 }
 
-func (store *InMemoryStore) SaveURL(originalURL, shortCode string) error {
+func (store *InMemoryStore) SaveURL(link Link) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	if existingOrig, ok := store.shortToOrig[shortCode]; ok && existingOrig != originalURL {
-		return fmt.Errorf("%w: short code '%s' already maps to '%s'", ErrDuplicateShortCode, shortCode, existingOrig)
+	shortKey := domainKey{link.Domain, link.ShortCode}
+	origKey := domainKey{link.Domain, link.OriginURL}
+
+	if existing, ok := store.shortToLink[shortKey]; ok {
+		if existing.OriginURL != link.OriginURL {
+			return fmt.Errorf("%w: short code '%s' already maps to '%s'", ErrDuplicateShortCode, link.ShortCode, logging.RedactURL(existing.OriginURL))
+		}
+		return nil
 	}
 
-	if existingShort, ok := store.origToShort[originalURL]; ok && existingShort != shortCode {
-		delete(store.shortToOrig, existingShort)
+	plain := link.Plain()
+	if existingShort, ok := store.origToShort[origKey]; ok && plain {
+		return fmt.Errorf("%w: '%s' is shortened to '%s'", ErrDuplicateOriginURL, logging.RedactURL(link.OriginURL), existingShort)
 	}
 
-	store.shortToOrig[shortCode] = originalURL
-	store.origToShort[originalURL] = shortCode
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now().UTC()
+	}
+	link.Tags = slices.Clone(link.Tags)
+	store.shortToLink[shortKey] = link
+	if plain {
+		store.origToShort[origKey] = link.ShortCode
+	}
 
 	return nil
 }
 
-func (store *InMemoryStore) GetOriginURL(shortCode string) (string, error) {
+func (store *InMemoryStore) GetOriginURL(domain, shortCode string) (string, error) {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
-	originUrl, ok := store.shortToOrig[shortCode]
+	link, ok := store.shortToLink[domainKey{domain, shortCode}]
 	if !ok {
 		return "", ErrNotFound
 	}
 
-	return originUrl, nil
+	return link.OriginURL, nil
 }
 
-func (store *InMemoryStore) GetShortURL(originalURL string) (string, error) {
+func (store *InMemoryStore) GetShortURL(domain, originalURL string) (string, error) {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
-	shortCode, ok := store.origToShort[originalURL]
+	shortCode, ok := store.origToShort[domainKey{domain, originalURL}]
 	if !ok {
 		return "", ErrNotFound
 	}
 
 	return shortCode, nil
-}
\ No newline at end of file
+}
+
+func (store *InMemoryStore) GetLink(domain, shortCode string) (Link, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	link, ok := store.shortToLink[domainKey{domain, shortCode}]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+
+	link.Tags = slices.Clone(link.Tags)
+	return link, nil
+}
+
+func (store *InMemoryStore) AddClicks(domain, shortCode string, n int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := domainKey{domain, shortCode}
+	link, ok := store.shortToLink[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	link.Clicks += n
+	store.shortToLink[key] = link
+	return nil
+}
+
+// ListLinks scans all links, which is fine for the sizes this store is
+// meant for.
+func (store *InMemoryStore) ListLinks(query ListQuery) (ListPage, error) {
+	sort, after, err := prepareQuery(query)
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	store.mu.RLock()
+	var links []Link
+	for _, link := range store.shortToLink {
+		if len(query.Domains) > 0 && !slices.Contains(query.Domains, link.Domain) {
+			continue
+		}
+		if query.Owner != "" && link.Owner != query.Owner {
+			continue
+		}
+		if query.Tag != "" && !slices.Contains(link.Tags, query.Tag) {
+			continue
+		}
+		if after != nil && !after.afterCursor(link) {
+			continue
+		}
+		link.Tags = slices.Clone(link.Tags)
+		links = append(links, link)
+	}
+	store.mu.RUnlock()
+
+	slices.SortFunc(links, func(a, b Link) int {
+		if before(sort, a, b) {
+			return -1
+		}
+		return 1
+	})
+
+	var page ListPage
+	if len(links) > query.Limit {
+		links = links[:query.Limit]
+		page.NextCursor = cursorAfter(sort, links[len(links)-1])
+	}
+	page.Links = links
+	return page, nil
+}
+
+func (store *InMemoryStore) UpdateMetadata(domain, shortCode string, meta Metadata) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := domainKey{domain, shortCode}
+	link, ok := store.shortToLink[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	meta.Tags = slices.Clone(meta.Tags)
+	link.Metadata = meta
+	store.shortToLink[key] = link
+	return nil
+}
+
+func (store *InMemoryStore) DeleteURL(domain, shortCode string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	shortKey := domainKey{domain, shortCode}
+	link, ok := store.shortToLink[shortKey]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(store.shortToLink, shortKey)
+	origKey := domainKey{domain, link.OriginURL}
+	if store.origToShort[origKey] == shortCode {
+		delete(store.origToShort, origKey)
+	}
+
+	return nil
+}
+
+func (store *InMemoryStore) ReplaceURL(link Link) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	shortKey := domainKey{link.Domain, link.ShortCode}
+	origKey := domainKey{link.Domain, link.OriginURL}
+	existing, ok := store.shortToLink[shortKey]
+	if !ok {
+		return ErrNotFound
+	}
+
+	plain := link.Plain()
+	if existingShort, ok := store.origToShort[origKey]; ok && plain && existingShort != link.ShortCode {
+		return fmt.Errorf("%w: '%s' is shortened to '%s'", ErrDuplicateOriginURL, logging.RedactURL(link.OriginURL), existingShort)
+	}
+
+	oldOrigKey := domainKey{link.Domain, existing.OriginURL}
+	if store.origToShort[oldOrigKey] == link.ShortCode {
+		delete(store.origToShort, oldOrigKey)
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now().UTC()
+	}
+	link.Tags = slices.Clone(link.Tags)
+	store.shortToLink[shortKey] = link
+	if plain {
+		store.origToShort[origKey] = link.ShortCode
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/storage"
+	"github.com/vadyaov/url_shortener/internal/storage/storagetest"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.URLStore {
+		return storage.NewInMemoryStore(logging.Discard())
+	})
+}
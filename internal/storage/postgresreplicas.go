@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DefaultReadAfterWrite       = 5 * time.Second
+	DefaultReplicaCheckInterval = 5 * time.Second
+)
+
+// PostgresOptions configures the read replicas of PostgresStore. The zero
+// value sends all queries to the primary.
+type PostgresOptions struct {
+	// DSNs of streaming replicas of the primary. Lookups of links
+	// (GetOriginURL, GetShortURL, GetLink) are spread over the healthy
+	// ones; everything else uses the primary. Click counters of links
+	// read from a replica may lag behind.
+	ReplicaDSNs []string
+	// How long lookups of a link saved, changed or deleted by this store
+	// go to the primary, covering the replication lag. 0 means
+	// DefaultReadAfterWrite.
+	ReadAfterWrite time.Duration
+	// Interval between pings of the replicas. A failed replica gets no
+	// lookups until a ping succeeds again. 0 means
+	// DefaultReplicaCheckInterval.
+	ReplicaCheckInterval time.Duration
+}
+
+type replica struct {
+	addr    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// replicaSet picks the replica for a lookup and remembers recently written
+// links, which are read from the primary.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	window   time.Duration
+	log      *slog.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	written   map[domainKey]time.Time // link key -> end of its window
+	lastSweep time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// newReplicaSet connects to the replicas. An unreachable replica does not
+// fail the store, it is only left out until it answers a ping.
+func newReplicaSet(ctx context.Context, opts PostgresOptions, logger *slog.Logger) (*replicaSet, error) {
+	set := &replicaSet{
+		window:  opts.ReadAfterWrite,
+		log:     logger,
+		now:     time.Now,
+		written: make(map[domainKey]time.Time),
+		done:    make(chan struct{}),
+	}
+	if set.window <= 0 {
+		set.window = DefaultReadAfterWrite
+	}
+	interval := opts.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	for _, dsn := range opts.ReplicaDSNs {
+		pool, err := newPool(ctx, dsn)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		config := pool.Config().ConnConfig
+		r := &replica{addr: net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))), pool: pool}
+		set.replicas = append(set.replicas, r)
+
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err = pool.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			logger.Warn("postgres replica unavailable", slog.String("replica", r.addr), slog.Any("error", err))
+		} else {
+			r.healthy.Store(true)
+		}
+	}
+
+	checkCtx, stop := context.WithCancel(ctx)
+	set.stop = stop
+	go set.check(checkCtx, interval)
+	return set, nil
+}
+
+// check pings the replicas every interval until ctx is done.
+func (set *replicaSet) check(ctx context.Context, interval time.Duration) {
+	defer close(set.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, r := range set.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := r.pool.Ping(pingCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				set.markDown(r, err)
+			} else if !r.healthy.Swap(true) {
+				set.log.Info("postgres replica available again", slog.String("replica", r.addr))
+			}
+		}
+	}
+}
+
+func (set *replicaSet) markDown(r *replica, err error) {
+	if r.healthy.Swap(false) {
+		set.log.Warn("postgres replica failed, reading from the primary", slog.String("replica", r.addr), slog.Any("error", err))
+	}
+}
+
+// pick returns the next healthy replica for a lookup of keys, or nil if
+// the lookup has to go to the primary.
+func (set *replicaSet) pick(keys ...domainKey) *replica {
+	if set == nil || len(set.replicas) == 0 {
+		return nil
+	}
+	if set.recentlyWritten(keys) {
+		return nil
+	}
+	start := set.next.Add(1)
+	for i := range uint64(len(set.replicas)) {
+		r := set.replicas[(start+i)%uint64(len(set.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+func (set *replicaSet) recentlyWritten(keys []domainKey) bool {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	now := set.now()
+	for _, key := range keys {
+		if until, ok := set.written[key]; ok && now.Before(until) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrote starts the read-after-write window of keys.
+func (set *replicaSet) wrote(keys ...domainKey) {
+	if set == nil || len(set.replicas) == 0 {
+		return
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	now := set.now()
+	if now.Sub(set.lastSweep) > set.window {
+		for key, until := range set.written {
+			if !now.Before(until) {
+				delete(set.written, key)
+			}
+		}
+		set.lastSweep = now
+	}
+	for _, key := range keys {
+		set.written[key] = now.Add(set.window)
+	}
+}
+
+func (set *replicaSet) close() {
+	if set.stop != nil {
+		set.stop()
+		<-set.done
+	}
+	for _, r := range set.replicas {
+		r.pool.Close()
+	}
+}
+
+// readRow scans a single row lookup from a replica if one is picked for
+// keys. It falls back to the primary when the replica fails or does not
+// have the row: it may not have replicated yet, e.g. when another instance
+// created the link.
+func (store *PostgresStore) readRow(keys []domainKey, scan func(pgx.Row) error, query string, args ...any) error {
+	if r := store.replicas.pick(keys...); r != nil {
+		err := scan(r.pool.QueryRow(store.ctx, query, args...))
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, pgx.ErrNoRows) || store.ctx.Err() != nil:
+			// не ошибка реплики, повторяем запрос на primary
+		default:
+			store.replicas.markDown(r, err)
+		}
+	}
+	return scan(store.pool.QueryRow(store.ctx, query, args...))
+}
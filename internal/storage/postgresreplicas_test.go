@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+// clock is a fake time source for replicaSet.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestReplicaSet returns a set of healthy replicas without connections.
+func newTestReplicaSet(n int) (*replicaSet, *clock) {
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	set := &replicaSet{
+		window:  time.Second,
+		log:     logging.Discard(),
+		now:     c.now,
+		written: make(map[domainKey]time.Time),
+	}
+	for _, addr := range []string{"replica1:5432", "replica2:5432", "replica3:5432"}[:n] {
+		r := &replica{addr: addr}
+		r.healthy.Store(true)
+		set.replicas = append(set.replicas, r)
+	}
+	return set, c
+}
+
+// picks returns the addresses of n picks, "" for the primary.
+func picks(set *replicaSet, n int, keys ...domainKey) map[string]int {
+	got := map[string]int{}
+	for range n {
+		addr := ""
+		if r := set.pick(keys...); r != nil {
+			addr = r.addr
+		}
+		got[addr]++
+	}
+	return got
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	var none *replicaSet
+	if r := none.pick(domainKey{"", "abc"}); r != nil {
+		t.Errorf("nil set picked %q", r.addr)
+	}
+	none.wrote(domainKey{"", "abc"})
+
+	set, _ := newTestReplicaSet(3)
+	got := picks(set, 6)
+	if len(got) != 3 || got["replica1:5432"] != 2 || got["replica2:5432"] != 2 || got["replica3:5432"] != 2 {
+		t.Errorf("picks = %v, want round robin", got)
+	}
+
+	// a failed replica gets no lookups until it is healthy again
+	set.markDown(set.replicas[1], errors.New("connection refused"))
+	if got := picks(set, 6); got["replica2:5432"] != 0 || got["replica1:5432"]+got["replica3:5432"] != 6 {
+		t.Errorf("picks with replica2 down = %v", got)
+	}
+	set.markDown(set.replicas[1], errors.New("still down"))
+	set.markDown(set.replicas[0], errors.New("connection refused"))
+	set.markDown(set.replicas[2], errors.New("connection refused"))
+	if got := picks(set, 3); got[""] != 3 {
+		t.Errorf("picks with all replicas down = %v, want the primary", got)
+	}
+	set.replicas[1].healthy.Store(true)
+	if got := picks(set, 3); got["replica2:5432"] != 3 {
+		t.Errorf("picks after recovery = %v", got)
+	}
+}
+
+func TestReplicaSetReadAfterWrite(t *testing.T) {
+	set, c := newTestReplicaSet(1)
+	code, url := domainKey{"", "abc"}, domainKey{"", "https://example.com"}
+	other := domainKey{"go.example", "abc"}
+
+	set.wrote(code, url)
+	if !set.recentlyWritten([]domainKey{code}) || !set.recentlyWritten([]domainKey{other, url}) {
+		t.Error("written keys are not recent")
+	}
+	if set.recentlyWritten([]domainKey{other}) {
+		t.Error("key of another domain is recent")
+	}
+	if r := set.pick(code); r != nil {
+		t.Errorf("recently written key read from %q", r.addr)
+	}
+	if r := set.pick(other); r == nil {
+		t.Error("other key read from the primary")
+	}
+
+	c.advance(set.window - time.Millisecond)
+	if set.pick(code) != nil {
+		t.Error("key left the window early")
+	}
+	c.advance(time.Millisecond)
+	if set.recentlyWritten([]domainKey{code, url}) || set.pick(code) == nil {
+		t.Error("key still recent after the window")
+	}
+
+	// writing again restarts the window
+	set.wrote(code)
+	c.advance(set.window / 2)
+	if !set.recentlyWritten([]domainKey{code}) {
+		t.Error("rewritten key is not recent")
+	}
+}
+
+func TestReplicaSetSweep(t *testing.T) {
+	set, c := newTestReplicaSet(1)
+	set.wrote(domainKey{"", "old"})
+	c.advance(2 * set.window)
+	set.wrote(domainKey{"", "new"})
+	if _, ok := set.written[domainKey{"", "old"}]; ok || len(set.written) != 1 {
+		t.Errorf("written = %v, want expired keys swept", set.written)
+	}
+
+	// expired keys stay until a window has passed since the last sweep
+	c.advance(set.window)
+	set.wrote(domainKey{"", "newer"})
+	if len(set.written) != 2 {
+		t.Errorf("written = %v, swept within a window of the last sweep", set.written)
+	}
+	if set.recentlyWritten([]domainKey{{"", "new"}}) {
+		t.Error("expired key is recent")
+	}
+}
@@ -1,22 +1,104 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 var ErrNotFound = errors.New("URL not found")
 var ErrDuplicateShortCode = errors.New("short code already exists for a different URL")
+var ErrDuplicateOriginURL = errors.New("original URL already has a plain link with a different short code")
+
+// Link is a stored mapping between a short code and the original URL.
+type Link struct {
+	// Short domain the code belongs to. Codes are unique per domain;
+	// the empty string stands for the default domain.
+	Domain    string
+	ShortCode string
+	OriginURL string
+	// ID of the API key which created the link. Empty for links
+	// created before API keys were introduced.
+	Owner     string
+	CreatedAt time.Time
+	// bcrypt hash of the link password, empty for public links.
+	PasswordHash string
+	// Always show the preview page instead of redirecting immediately
+	Interstitial bool
+	// Number of redirects served for the link
+	Clicks int64
+	// The link was created for one caller (e.g. with its own metadata)
+	// and is never handed out for the same original URL again
+	Dedicated bool
+	Metadata
+}
+
+// Metadata is free-form information about a link which does not affect
+// redirects.
+type Metadata struct {
+	Title       string
+	Description string
+	Tags        []string
+}
+
+// Plain reports whether the link has no password, no interstitial and is
+// not dedicated. Only plain links take part in the original URL -> short
+// code mapping, so GetShortURL never hands out a link with extra behaviour.
+func (l Link) Plain() bool {
+	return l.PasswordHash == "" && !l.Interstitial && !l.Dedicated
+}
 
 type URLStore interface {
-	// Save the mapping between originalUrl and shortCode.
+	// Save the mapping between link.OriginURL and link.ShortCode within link.Domain.
 	// Need to check the case if shortCode already exists
-	SaveURL(originalURL, shortCode string) error
+	// Saving an already existing mapping keeps the stored owner.
+	// Returns ErrDuplicateShortCode if shortCode maps to a different URL and
+	// ErrDuplicateOriginURL if link is plain and a plain link with another
+	// code exists for link.OriginURL
+	SaveURL(link Link) error
 
 	// Returns original URL from the short code
 	// Returns ErrNotFound if the URL does not exist
-	GetOriginURL(shortCode string) (string, error)
+	GetOriginURL(domain, shortCode string) (string, error)
+
+	// Returns short code of the plain link for the original URL
+	// Returns ErrNotFound if the URL does not exist
+	GetShortURL(domain, originURL string) (string, error)
+
+	// Returns the full record for the short code
+	// Returns ErrNotFound if the URL does not exist
+	GetLink(domain, shortCode string) (Link, error)
 
-	// Returns short code from the original URL
+	// Adds n to the click counter of the short code
 	// Returns ErrNotFound if the URL does not exist
-	GetShortURL(originURL string) (string, error)
-}
\ No newline at end of file
+	AddClicks(domain, shortCode string, n int64) error
+
+	// Replaces the metadata of the short code
+	// Returns ErrNotFound if the URL does not exist
+	UpdateMetadata(domain, shortCode string, meta Metadata) error
+
+	// Returns a page of links matching the query
+	// Returns ErrInvalidCursor if query.Cursor is malformed or was
+	// issued for a different sort order
+	ListLinks(query ListQuery) (ListPage, error)
+
+	// Removes the mapping for the short code
+	// Returns ErrNotFound if the URL does not exist
+	DeleteURL(domain, shortCode string) error
+}
+
+// Pinger is implemented by stores backed by an external service
+// which can become unavailable (e.g. PostgresStore).
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Replacer is implemented by stores which can replace a link in one step
+// (e.g. within a transaction), so a failed replace keeps the old link.
+type Replacer interface {
+	// Replaces the link stored under link.ShortCode within link.Domain
+	// Returns ErrNotFound if the URL does not exist and
+	// ErrDuplicateOriginURL if link is plain and a plain link with another
+	// code exists for link.OriginURL
+	ReplaceURL(link Link) error
+}
@@ -0,0 +1,659 @@
+// Package storagetest checks implementations of storage.URLStore against
+// the contract documented on the interface: duplicate handling of SaveURL,
+// the short code <-> original URL mappings, ErrNotFound, paging of
+// ListLinks and safety under concurrent writers.
+//
+// A backend runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.URLStore {
+//			return newEmptyStore(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// Run runs the conformance tests as subtests of t. Every subtest gets an
+// empty store from newStore, which registers its cleanup with t. Subtests
+// do not run in parallel, so stores may share a database.
+func Run(t *testing.T, newStore func(t *testing.T) storage.URLStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.URLStore)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"MissingLink", testMissingLink},
+		{"SaveExistingMapping", testSaveExistingMapping},
+		{"DuplicateShortCode", testDuplicateShortCode},
+		{"DuplicateOriginURL", testDuplicateOriginURL},
+		{"NonPlainLinks", testNonPlainLinks},
+		{"Domains", testDomains},
+		{"DeleteURL", testDeleteURL},
+		{"ReplaceURL", testReplaceURL},
+		{"AddClicks", testAddClicks},
+		{"UpdateMetadata", testUpdateMetadata},
+		{"ReturnsCopies", testReturnsCopies},
+		{"ListLinksFilters", testListLinksFilters},
+		{"ListLinksPaging", testListLinksPaging},
+		{"ListLinksInvalidQuery", testListLinksInvalidQuery},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ConcurrentSameShortCode", testConcurrentSameShortCode},
+		{"ConcurrentSameOriginURL", testConcurrentSameOriginURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// baseTime is the creation time of test links. Whole seconds survive the
+// timestamp precision of any reasonable backend.
+var baseTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func plainLink(code, url string) storage.Link {
+	return storage.Link{ShortCode: code, OriginURL: url, CreatedAt: baseTime}
+}
+
+func save(t *testing.T, store storage.URLStore, links ...storage.Link) {
+	t.Helper()
+	for _, link := range links {
+		if err := store.SaveURL(link); err != nil {
+			t.Fatalf("SaveURL(%q, %q): %v", link.Domain, link.ShortCode, err)
+		}
+	}
+}
+
+func getLink(t *testing.T, store storage.URLStore, domain, code string) storage.Link {
+	t.Helper()
+	link, err := store.GetLink(domain, code)
+	if err != nil {
+		t.Fatalf("GetLink(%q, %q): %v", domain, code, err)
+	}
+	return link
+}
+
+func checkOrigin(t *testing.T, store storage.URLStore, domain, code, want string) {
+	t.Helper()
+	got, err := store.GetOriginURL(domain, code)
+	if err != nil || got != want {
+		t.Errorf("GetOriginURL(%q, %q) = %q, %v; want %q", domain, code, got, err, want)
+	}
+}
+
+func checkShort(t *testing.T, store storage.URLStore, domain, url, want string) {
+	t.Helper()
+	got, err := store.GetShortURL(domain, url)
+	if want == "" {
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetShortURL(%q, %q) = %q, %v; want ErrNotFound", domain, url, got, err)
+		}
+		return
+	}
+	if err != nil || got != want {
+		t.Errorf("GetShortURL(%q, %q) = %q, %v; want %q", domain, url, got, err, want)
+	}
+}
+
+// checkLink compares the stored fields of two links. Empty and nil tags
+// are the same.
+func checkLink(t *testing.T, got, want storage.Link) {
+	t.Helper()
+	if got.Domain != want.Domain || got.ShortCode != want.ShortCode || got.OriginURL != want.OriginURL ||
+		got.Owner != want.Owner || !got.CreatedAt.Equal(want.CreatedAt) || got.PasswordHash != want.PasswordHash ||
+		got.Interstitial != want.Interstitial || got.Clicks != want.Clicks || got.Dedicated != want.Dedicated ||
+		got.Title != want.Title || got.Description != want.Description || !slices.Equal(got.Tags, want.Tags) {
+		t.Errorf("link = %+v\nwant %+v", got, want)
+	}
+}
+
+func testSaveAndGet(t *testing.T, store storage.URLStore) {
+	link := storage.Link{
+		Domain:    "sho.rt",
+		ShortCode: "abc1234",
+		OriginURL: "https://example.com/a?b=c",
+		Owner:     "key1",
+		CreatedAt: baseTime,
+		Clicks:    5,
+		Metadata:  storage.Metadata{Title: "Title", Description: "Description", Tags: []string{"x", "y"}},
+	}
+	save(t, store, link)
+
+	checkLink(t, getLink(t, store, link.Domain, link.ShortCode), link)
+	checkOrigin(t, store, link.Domain, link.ShortCode, link.OriginURL)
+	checkShort(t, store, link.Domain, link.OriginURL, link.ShortCode)
+
+	// a zero creation time is set by the store
+	before := time.Now()
+	save(t, store, storage.Link{ShortCode: "now1234", OriginURL: "https://example.com/now"})
+	created := getLink(t, store, "", "now1234").CreatedAt
+	if created.Before(before.Add(-time.Minute)) || created.After(time.Now().Add(time.Minute)) {
+		t.Errorf("CreatedAt = %v, want about %v", created, before)
+	}
+}
+
+func testMissingLink(t *testing.T, store storage.URLStore) {
+	save(t, store, plainLink("exists1", "https://example.com/exists"))
+
+	check := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s: got %v, want ErrNotFound", name, err)
+		}
+	}
+	_, err := store.GetOriginURL("", "missing")
+	check("GetOriginURL", err)
+	_, err = store.GetShortURL("", "https://example.com/missing")
+	check("GetShortURL", err)
+	_, err = store.GetLink("", "missing")
+	check("GetLink", err)
+	check("AddClicks", store.AddClicks("", "missing", 1))
+	check("UpdateMetadata", store.UpdateMetadata("", "missing", storage.Metadata{Title: "t"}))
+	check("DeleteURL", store.DeleteURL("", "missing"))
+
+	// the code exists in the default domain only
+	_, err = store.GetOriginURL("other.example", "exists1")
+	check("GetOriginURL in another domain", err)
+}
+
+func testSaveExistingMapping(t *testing.T, store storage.URLStore) {
+	link := plainLink("same123", "https://example.com/same")
+	link.Owner = "first"
+	save(t, store, link)
+
+	again := link
+	again.Owner = "second"
+	again.CreatedAt = baseTime.Add(time.Hour)
+	if err := store.SaveURL(again); err != nil {
+		t.Fatalf("saving the existing mapping again: %v", err)
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+	checkShort(t, store, "", link.OriginURL, link.ShortCode)
+}
+
+func testDuplicateShortCode(t *testing.T, store storage.URLStore) {
+	link := plainLink("dup1234", "https://example.com/first")
+	save(t, store, link)
+
+	for _, other := range []storage.Link{
+		plainLink("dup1234", "https://example.com/second"),
+		{ShortCode: "dup1234", OriginURL: "https://example.com/third", Dedicated: true},
+	} {
+		err := store.SaveURL(other)
+		if !errors.Is(err, storage.ErrDuplicateShortCode) {
+			t.Errorf("SaveURL(%q -> %q) = %v, want ErrDuplicateShortCode", other.ShortCode, other.OriginURL, err)
+		}
+		checkShort(t, store, "", other.OriginURL, "")
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+	checkShort(t, store, "", link.OriginURL, link.ShortCode)
+}
+
+func testDuplicateOriginURL(t *testing.T, store storage.URLStore) {
+	link := plainLink("orig123", "https://example.com/orig")
+	save(t, store, link)
+
+	err := store.SaveURL(plainLink("orig456", link.OriginURL))
+	if !errors.Is(err, storage.ErrDuplicateOriginURL) {
+		t.Fatalf("SaveURL of a second plain link = %v, want ErrDuplicateOriginURL", err)
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+	checkShort(t, store, "", link.OriginURL, link.ShortCode)
+	if _, err := store.GetLink("", "orig456"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetLink of the rejected link = %v, want ErrNotFound", err)
+	}
+
+	// once the plain link is gone, another code can take its place
+	if err := store.DeleteURL("", link.ShortCode); err != nil {
+		t.Fatal(err)
+	}
+	save(t, store, plainLink("orig456", link.OriginURL))
+	checkShort(t, store, "", link.OriginURL, "orig456")
+}
+
+func testNonPlainLinks(t *testing.T, store storage.URLStore) {
+	const url = "https://example.com/shared"
+	others := []storage.Link{
+		{ShortCode: "pass123", OriginURL: url, PasswordHash: "$2a$10$hash", CreatedAt: baseTime},
+		{ShortCode: "inter12", OriginURL: url, Interstitial: true, CreatedAt: baseTime},
+		{ShortCode: "dedic12", OriginURL: url, Dedicated: true, CreatedAt: baseTime,
+			Metadata: storage.Metadata{Title: "Own"}},
+	}
+	save(t, store, others...)
+	// links with extra behaviour are never handed out for the URL
+	checkShort(t, store, "", url, "")
+
+	plain := plainLink("plain12", url)
+	save(t, store, plain)
+	checkShort(t, store, "", url, plain.ShortCode)
+
+	// and several of them may exist next to the plain link
+	save(t, store, storage.Link{ShortCode: "dedic34", OriginURL: url, Dedicated: true, CreatedAt: baseTime})
+	for _, link := range others {
+		checkLink(t, getLink(t, store, "", link.ShortCode), link)
+	}
+	checkShort(t, store, "", url, plain.ShortCode)
+
+	// deleting one of them keeps the plain mapping
+	if err := store.DeleteURL("", "dedic34"); err != nil {
+		t.Fatal(err)
+	}
+	checkShort(t, store, "", url, plain.ShortCode)
+}
+
+func testDomains(t *testing.T, store storage.URLStore) {
+	a := plainLink("code123", "https://example.com/a")
+	b := plainLink("code123", "https://example.com/b")
+	b.Domain = "sho.rt"
+	c := plainLink("other12", "https://example.com/a")
+	c.Domain = "sho.rt"
+	save(t, store, a, b, c)
+
+	checkOrigin(t, store, "", "code123", a.OriginURL)
+	checkOrigin(t, store, "sho.rt", "code123", b.OriginURL)
+	checkShort(t, store, "", a.OriginURL, "code123")
+	checkShort(t, store, "sho.rt", a.OriginURL, "other12")
+	checkShort(t, store, "", b.OriginURL, "")
+
+	if err := store.DeleteURL("sho.rt", "code123"); err != nil {
+		t.Fatal(err)
+	}
+	checkOrigin(t, store, "", "code123", a.OriginURL)
+}
+
+func testDeleteURL(t *testing.T, store storage.URLStore) {
+	link := plainLink("del1234", "https://example.com/del")
+	save(t, store, link)
+
+	if err := store.DeleteURL("", link.ShortCode); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	if _, err := store.GetOriginURL("", link.ShortCode); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetOriginURL after delete = %v, want ErrNotFound", err)
+	}
+	checkShort(t, store, "", link.OriginURL, "")
+	if err := store.DeleteURL("", link.ShortCode); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("second DeleteURL = %v, want ErrNotFound", err)
+	}
+
+	// the code is free again, also for another URL
+	save(t, store, plainLink(link.ShortCode, "https://example.com/new"))
+	checkOrigin(t, store, "", link.ShortCode, "https://example.com/new")
+}
+
+func testReplaceURL(t *testing.T, store storage.URLStore) {
+	replacer, ok := store.(storage.Replacer)
+	if !ok {
+		t.Skip("store does not implement storage.Replacer")
+	}
+	old := plainLink("repl123", "https://example.com/old")
+	other := plainLink("other12", "https://example.com/other")
+	save(t, store, old, other)
+
+	if err := replacer.ReplaceURL(plainLink("missing", "https://example.com/x")); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("ReplaceURL of a missing code = %v, want ErrNotFound", err)
+	}
+
+	// the plain URL of another code: nothing changes
+	taken := plainLink(old.ShortCode, other.OriginURL)
+	if err := replacer.ReplaceURL(taken); !errors.Is(err, storage.ErrDuplicateOriginURL) {
+		t.Errorf("ReplaceURL with a taken URL = %v, want ErrDuplicateOriginURL", err)
+	}
+	checkLink(t, getLink(t, store, "", old.ShortCode), old)
+	checkShort(t, store, "", old.OriginURL, old.ShortCode)
+
+	link := plainLink(old.ShortCode, "https://example.com/new")
+	link.Owner, link.Clicks, link.Metadata = "key1", 3, storage.Metadata{Title: "New", Tags: []string{"a"}}
+	if err := replacer.ReplaceURL(link); err != nil {
+		t.Fatalf("ReplaceURL: %v", err)
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+	checkShort(t, store, "", old.OriginURL, "")
+	checkShort(t, store, "", link.OriginURL, link.ShortCode)
+
+	// a protected link leaves the reverse mapping
+	link.PasswordHash = "hash"
+	if err := replacer.ReplaceURL(link); err != nil {
+		t.Fatalf("ReplaceURL with password: %v", err)
+	}
+	checkShort(t, store, "", link.OriginURL, "")
+}
+
+func testAddClicks(t *testing.T, store storage.URLStore) {
+	link := plainLink("click12", "https://example.com/click")
+	link.Clicks = 2
+	save(t, store, link)
+
+	for _, n := range []int64{1, 10, 0} {
+		if err := store.AddClicks("", link.ShortCode, n); err != nil {
+			t.Fatalf("AddClicks(%d): %v", n, err)
+		}
+	}
+	if got := getLink(t, store, "", link.ShortCode).Clicks; got != 13 {
+		t.Errorf("Clicks = %d, want 13", got)
+	}
+}
+
+func testUpdateMetadata(t *testing.T, store storage.URLStore) {
+	link := plainLink("meta123", "https://example.com/meta")
+	link.Metadata = storage.Metadata{Title: "Old", Description: "Old description", Tags: []string{"a", "b"}}
+	save(t, store, link)
+
+	link.Metadata = storage.Metadata{Title: "New", Tags: []string{"c"}}
+	if err := store.UpdateMetadata("", link.ShortCode, link.Metadata); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+
+	// the metadata is replaced as a whole
+	link.Metadata = storage.Metadata{}
+	if err := store.UpdateMetadata("", link.ShortCode, link.Metadata); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	checkLink(t, getLink(t, store, "", link.ShortCode), link)
+}
+
+// testReturnsCopies checks that callers cannot change stored links through
+// the slices they passed in or got back.
+func testReturnsCopies(t *testing.T, store storage.URLStore) {
+	tags := []string{"a", "b"}
+	link := plainLink("copy123", "https://example.com/copy")
+	link.Tags = tags
+	save(t, store, link)
+	tags[0] = "changed"
+
+	got := getLink(t, store, "", link.ShortCode)
+	if !slices.Equal(got.Tags, []string{"a", "b"}) {
+		t.Fatalf("Tags = %q after changing the saved slice", got.Tags)
+	}
+	got.Tags[0] = "changed"
+
+	page, err := store.ListLinks(storage.ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Links) != 1 || !slices.Equal(page.Links[0].Tags, []string{"a", "b"}) {
+		t.Fatalf("ListLinks = %+v after changing a returned link", page.Links)
+	}
+	page.Links[0].Tags[1] = "changed"
+
+	meta := storage.Metadata{Tags: []string{"c"}}
+	if err := store.UpdateMetadata("", link.ShortCode, meta); err != nil {
+		t.Fatal(err)
+	}
+	meta.Tags[0] = "changed"
+
+	if got := getLink(t, store, "", link.ShortCode).Tags; !slices.Equal(got, []string{"c"}) {
+		t.Errorf("Tags = %q, want [c]", got)
+	}
+}
+
+// listAll collects the codes of all pages of the query.
+func listAll(t *testing.T, store storage.URLStore, query storage.ListQuery) []string {
+	t.Helper()
+	var codes []string
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatalf("ListLinks(%+v) does not end", query)
+		}
+		result, err := store.ListLinks(query)
+		if err != nil {
+			t.Fatalf("ListLinks(%+v): %v", query, err)
+		}
+		if len(result.Links) > query.Limit {
+			t.Fatalf("ListLinks returned %d links, limit %d", len(result.Links), query.Limit)
+		}
+		for _, link := range result.Links {
+			codes = append(codes, link.Domain+"/"+link.ShortCode)
+		}
+		if result.NextCursor == "" {
+			return codes
+		}
+		query.Cursor = result.NextCursor
+	}
+}
+
+func testListLinksFilters(t *testing.T, store storage.URLStore) {
+	links := []storage.Link{
+		{ShortCode: "list001", OriginURL: "https://example.com/1", Owner: "alice", Metadata: storage.Metadata{Tags: []string{"go"}}},
+		{ShortCode: "list002", OriginURL: "https://example.com/2", Owner: "bob", Metadata: storage.Metadata{Tags: []string{"go", "db"}}},
+		{ShortCode: "list003", OriginURL: "https://example.com/3", Owner: "alice", Domain: "sho.rt"},
+		{ShortCode: "list004", OriginURL: "https://example.com/4", Owner: "alice", Domain: "other.example",
+			Dedicated: true, Metadata: storage.Metadata{Tags: []string{"db"}}},
+	}
+	for i := range links {
+		links[i].CreatedAt = baseTime.Add(time.Duration(i) * time.Minute)
+	}
+	save(t, store, links...)
+
+	tests := []struct {
+		query storage.ListQuery
+		want  []string
+	}{
+		{storage.ListQuery{}, []string{"other.example/list004", "sho.rt/list003", "/list002", "/list001"}},
+		{storage.ListQuery{Owner: "alice"}, []string{"other.example/list004", "sho.rt/list003", "/list001"}},
+		{storage.ListQuery{Tag: "db"}, []string{"other.example/list004", "/list002"}},
+		{storage.ListQuery{Domains: []string{""}}, []string{"/list002", "/list001"}},
+		{storage.ListQuery{Domains: []string{"sho.rt", "other.example"}, Owner: "alice", Tag: "db"}, []string{"other.example/list004"}},
+		{storage.ListQuery{Owner: "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		tt.query.Limit = 10
+		if got := listAll(t, store, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("ListLinks(%+v) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func testListLinksPaging(t *testing.T, store storage.URLStore) {
+	var links []storage.Link
+	for i := range 11 {
+		link := storage.Link{
+			ShortCode: fmt.Sprintf("page%03d", i),
+			OriginURL: fmt.Sprintf("https://example.com/page/%d", i),
+			// pairs of links share the creation time and a third of them
+			// the click count, so that ties are broken by domain and code
+			CreatedAt: baseTime.Add(time.Duration(i/2) * time.Minute),
+			Clicks:    int64(i % 3),
+		}
+		if i%4 == 0 {
+			link.Domain = "sho.rt"
+		}
+		links = append(links, link)
+	}
+	save(t, store, links...)
+
+	for _, sort := range []storage.SortOrder{"", storage.SortByCreated, storage.SortByClicks} {
+		expected := slices.Clone(links)
+		slices.SortFunc(expected, func(a, b storage.Link) int {
+			var c int
+			if sort == storage.SortByClicks {
+				c = cmp.Compare(b.Clicks, a.Clicks)
+			} else {
+				c = b.CreatedAt.Compare(a.CreatedAt)
+			}
+			if c == 0 {
+				c = cmp.Compare(b.Domain, a.Domain)
+			}
+			if c == 0 {
+				c = cmp.Compare(b.ShortCode, a.ShortCode)
+			}
+			return c
+		})
+		var want []string
+		for _, link := range expected {
+			want = append(want, link.Domain+"/"+link.ShortCode)
+		}
+
+		for _, limit := range []int{1, 3, 11, 20} {
+			query := storage.ListQuery{Sort: sort, Limit: limit}
+			if got := listAll(t, store, query); !slices.Equal(got, want) {
+				t.Errorf("ListLinks(sort %q, limit %d) = %q\nwant %q", sort, limit, got, want)
+			}
+		}
+	}
+
+	// the last full page has no next cursor
+	page, err := store.ListLinks(storage.ListQuery{Limit: len(links)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Links) != len(links) || page.NextCursor != "" {
+		t.Errorf("ListLinks(limit %d) = %d links, cursor %q; want all links without cursor", len(links), len(page.Links), page.NextCursor)
+	}
+}
+
+func testListLinksInvalidQuery(t *testing.T, store storage.URLStore) {
+	for i := range 3 {
+		save(t, store, plainLink(fmt.Sprintf("inv%04d", i), fmt.Sprintf("https://example.com/inv/%d", i)))
+	}
+	page, err := store.ListLinks(storage.ListQuery{Sort: storage.SortByClicks, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("ListLinks returned no next cursor")
+	}
+
+	for _, cursor := range []string{"not a cursor", "bm90IGpzb24", page.NextCursor} {
+		_, err := store.ListLinks(storage.ListQuery{Sort: storage.SortByCreated, Limit: 1, Cursor: cursor})
+		if !errors.Is(err, storage.ErrInvalidCursor) {
+			t.Errorf("ListLinks(cursor %q) = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+	for _, query := range []storage.ListQuery{{Limit: 0}, {Limit: -1}, {Sort: "title", Limit: 1}} {
+		if _, err := store.ListLinks(query); err == nil {
+			t.Errorf("ListLinks(%+v) succeeded, want an error", query)
+		}
+	}
+}
+
+// parallel runs fn concurrently n times and waits for all calls.
+func parallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+func testConcurrentWriters(t *testing.T, store storage.URLStore) {
+	const writers, clicks = 16, 25
+	shared := plainLink("shared1", "https://example.com/shared")
+	save(t, store, shared)
+
+	errs := make(chan error, writers*(clicks+3))
+	parallel(writers, func(i int) {
+		link := plainLink(fmt.Sprintf("conc%03d", i), fmt.Sprintf("https://example.com/conc/%d", i))
+		errs <- store.SaveURL(link)
+		errs <- store.UpdateMetadata("", link.ShortCode, storage.Metadata{Title: link.ShortCode})
+		for range clicks {
+			errs <- store.AddClicks("", shared.ShortCode, 1)
+		}
+		if i%2 == 0 {
+			errs <- store.DeleteURL("", link.ShortCode)
+		} else {
+			_, err := store.GetLink("", shared.ShortCode)
+			errs <- err
+		}
+	})
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write: %v", err)
+		}
+	}
+
+	if got := getLink(t, store, "", shared.ShortCode).Clicks; got != writers*clicks {
+		t.Errorf("Clicks = %d, want %d", got, writers*clicks)
+	}
+	for i := range writers {
+		code := fmt.Sprintf("conc%03d", i)
+		link, err := store.GetLink("", code)
+		switch {
+		case i%2 == 0 && !errors.Is(err, storage.ErrNotFound):
+			t.Errorf("GetLink(%q) of a deleted link = %v, want ErrNotFound", code, err)
+		case i%2 == 1 && err != nil:
+			t.Errorf("GetLink(%q): %v", code, err)
+		case i%2 == 1 && link.Title != code:
+			t.Errorf("GetLink(%q).Title = %q, want %q", code, link.Title, code)
+		}
+	}
+}
+
+func testConcurrentSameShortCode(t *testing.T, store storage.URLStore) {
+	const writers = 16
+	results := make([]error, writers)
+	parallel(writers, func(i int) {
+		results[i] = store.SaveURL(plainLink("race123", fmt.Sprintf("https://example.com/race/%d", i)))
+	})
+
+	winner := -1
+	for i, err := range results {
+		switch {
+		case err == nil && winner >= 0:
+			t.Errorf("both writers %d and %d saved the short code", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, storage.ErrDuplicateShortCode):
+			t.Errorf("writer %d: %v, want ErrDuplicateShortCode", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no writer saved the short code")
+	}
+	checkOrigin(t, store, "", "race123", fmt.Sprintf("https://example.com/race/%d", winner))
+
+	// saving the same mapping concurrently succeeds everywhere
+	link := plainLink("same123", "https://example.com/same")
+	parallel(writers, func(i int) { results[i] = store.SaveURL(link) })
+	for i, err := range results {
+		if err != nil {
+			t.Errorf("writer %d saving the same mapping: %v", i, err)
+		}
+	}
+	checkShort(t, store, "", link.OriginURL, link.ShortCode)
+}
+
+func testConcurrentSameOriginURL(t *testing.T, store storage.URLStore) {
+	const writers = 16
+	const url = "https://example.com/popular"
+	results := make([]error, writers)
+	parallel(writers, func(i int) {
+		results[i] = store.SaveURL(plainLink(fmt.Sprintf("pop%04d", i), url))
+	})
+
+	winner := -1
+	for i, err := range results {
+		switch {
+		case err == nil && winner >= 0:
+			t.Errorf("both writers %d and %d saved a plain link for the URL", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, storage.ErrDuplicateOriginURL):
+			t.Errorf("writer %d: %v, want ErrDuplicateOriginURL", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no writer saved a plain link")
+	}
+	checkShort(t, store, "", url, fmt.Sprintf("pop%04d", winner))
+}
@@ -0,0 +1,220 @@
+// Package tlsconfig builds server TLS configurations whose certificate is
+// reloaded from disk when the files change, e.g. after a renewal.
+package tlsconfig
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate policies.
+const (
+	// No client certificates
+	ClientAuthNone = "none"
+	// Certificates are verified if the client sends one
+	ClientAuthOptional = "optional"
+	// Every connection needs a verified certificate
+	ClientAuthRequire = "require"
+)
+
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// "1.2" or "1.3", 1.2 if empty
+	MinVersion string
+	// Names of TLS 1.2 cipher suites as in tls.CipherSuites, Go defaults
+	// if empty. TLS 1.3 suites are not configurable.
+	CipherSuites []string
+	// PEM bundle of CAs issuing client certificates, only read if
+	// ClientAuth is not ClientAuthNone
+	ClientCAFile string
+	ClientAuth   string
+	// Minimum time between checks of the files, 5s if zero
+	ReloadInterval time.Duration
+}
+
+// Certificate holds the current certificate and reloads it when the files
+// change. The files are checked on handshakes, at most once per reload
+// interval, so there is no background goroutine to stop.
+type Certificate struct {
+	certFile, keyFile string
+	interval          time.Duration
+	log               *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// NewCertificate loads the key pair.
+func NewCertificate(certFile, keyFile string, interval time.Duration, logger *slog.Logger) (*Certificate, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	c := &Certificate{certFile: certFile, keyFile: keyFile, interval: interval, log: logger}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Certificate) load() error {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+func (c *Certificate) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// Current returns the certificate, reloading it first if the files have
+// changed. A failed reload keeps the previous certificate.
+func (c *Certificate) Current() *tls.Certificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastCheck) < c.interval {
+		return c.cert
+	}
+	c.lastCheck = now
+
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		c.log.Warn("Failed to check TLS certificate files", slog.Any("error", err))
+		return c.cert
+	}
+	if certMod.Equal(c.certMod) && keyMod.Equal(c.keyMod) {
+		return c.cert
+	}
+	// the certificate and the key are rarely replaced at the same moment,
+	// a mismatching pair is retried on the next check
+	if err := c.load(); err != nil {
+		c.log.Warn("Failed to reload TLS certificate, keeping the previous one", slog.Any("error", err))
+		return c.cert
+	}
+	c.log.Info("TLS certificate reloaded", slog.String("cert", c.certFile))
+	return c.cert
+}
+
+// ServerConfig returns a TLS configuration serving the certificate.
+func ServerConfig(opts Options, logger *slog.Logger) (*tls.Config, *Certificate, error) {
+	cert, err := NewCertificate(opts.CertFile, opts.KeyFile, opts.ReloadInterval, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.Current(), nil
+		},
+	}
+	if cfg.MinVersion, err = ParseVersion(opts.MinVersion); err != nil {
+		return nil, nil, err
+	}
+	if cfg.CipherSuites, err = ParseCipherSuites(opts.CipherSuites); err != nil {
+		return nil, nil, err
+	}
+
+	switch opts.ClientAuth {
+	case "", ClientAuthNone:
+		return cfg, cert, nil
+	case ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, nil, fmt.Errorf("unknown client auth %q, use %q, %q or %q", opts.ClientAuth, ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+	}
+	if opts.ClientCAFile == "" {
+		return nil, nil, errors.New("client certificate verification needs a client CA file")
+	}
+	pem, err := os.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, nil, fmt.Errorf("no certificates in %s", opts.ClientCAFile)
+	}
+	return cfg, cert, nil
+}
+
+// PinnedClientConfig returns a client configuration which accepts only
+// the current certificate of cert, for connections of the application to
+// its own listeners. It works whatever names the certificate is issued
+// for.
+func PinnedClientConfig(cert *Certificate) *tls.Config {
+	return &tls.Config{
+		// verified below against the local certificate instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], cert.Current().Certificate[0]) {
+				return errors.New("server certificate does not match the local certificate")
+			}
+			return nil
+		},
+	}
+}
+
+// ParseVersion parses "1.2" or "1.3"; empty means 1.2.
+func ParseVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, use 1.2 or 1.3", s)
+}
+
+// ParseCipherSuites maps names of secure cipher suites to their IDs.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func cipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,385 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	pem    []byte
+	serial int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), serial: 1}
+}
+
+// issue returns a PEM certificate and key for name, a server or a client
+// certificate.
+func (ca *testCA) issue(t *testing.T, name string, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data and moves the modification time past the previous
+// one, which a rewrite within the file system's time granularity keeps.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	mod := time.Now()
+	if info, err := os.Stat(path); err == nil && !mod.After(info.ModTime()) {
+		mod = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func leafPEM(c *tls.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Certificate[0]})
+}
+
+func firstCert(t *testing.T, certPEM []byte) []byte {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	return pem.EncodeToMemory(block)
+}
+
+func TestCertificateReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert1, key1 := ca.issue(t, "one.test", false)
+	writeFile(t, certFile, cert1)
+	writeFile(t, keyFile, key1)
+
+	c, err := NewCertificate(certFile, keyFile, time.Nanosecond, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert1)) {
+		t.Fatal("not the loaded certificate")
+	}
+
+	cert2, key2 := ca.issue(t, "two.test", false)
+	writeFile(t, certFile, cert2)
+	writeFile(t, keyFile, key2)
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert2)) {
+		t.Error("certificate not reloaded after the files changed")
+	}
+}
+
+func TestCertificateKeepsPreviousOnMismatch(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert1, key1 := ca.issue(t, "one.test", false)
+	writeFile(t, certFile, cert1)
+	writeFile(t, keyFile, key1)
+
+	c, err := NewCertificate(certFile, keyFile, time.Nanosecond, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new certificate arrives before its key
+	cert2, key2 := ca.issue(t, "two.test", false)
+	writeFile(t, certFile, cert2)
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert1)) {
+		t.Error("mismatched pair replaced the certificate")
+	}
+
+	writeFile(t, keyFile, key2)
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert2)) {
+		t.Error("matching pair not loaded on the next check")
+	}
+
+	os.Remove(keyFile)
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert2)) {
+		t.Error("missing key file replaced the certificate")
+	}
+}
+
+func TestCertificateCheckInterval(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert1, key1 := ca.issue(t, "one.test", false)
+	writeFile(t, certFile, cert1)
+	writeFile(t, keyFile, key1)
+
+	c, err := NewCertificate(certFile, keyFile, time.Hour, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Current()
+
+	cert2, key2 := ca.issue(t, "two.test", false)
+	writeFile(t, certFile, cert2)
+	writeFile(t, keyFile, key2)
+	if got := leafPEM(c.Current()); !bytes.Equal(got, firstCert(t, cert1)) {
+		t.Error("files checked again before the interval passed")
+	}
+}
+
+func TestNewCertificateErrors(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cert1, _ := ca.issue(t, "one.test", false)
+	_, key2 := ca.issue(t, "two.test", false)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, certFile, cert1)
+	writeFile(t, keyFile, key2)
+
+	if _, err := NewCertificate(certFile, keyFile, 0, logging.Discard()); err == nil {
+		t.Error("mismatched pair loaded")
+	}
+	if _, err := NewCertificate(certFile, filepath.Join(dir, "missing.pem"), 0, logging.Discard()); err == nil {
+		t.Error("missing key loaded")
+	}
+}
+
+// handshake connects a client with clientCfg to a server with serverCfg
+// and returns the client's error.
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) error {
+	t.Helper()
+	// a TCP connection rather than net.Pipe: alerts are written while the
+	// other side is still writing too
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	serverConn.SetDeadline(deadline)
+	clientConn.SetDeadline(deadline)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer serverConn.Close()
+		server := tls.Server(serverConn, serverCfg)
+		if server.Handshake() == nil {
+			// TLS 1.3 clients see a refused certificate on the first read
+			server.Write([]byte("ok"))
+		}
+	}()
+
+	client := tls.Client(clientConn, clientCfg)
+	err = client.Handshake()
+	if err == nil {
+		_, err = client.Read(make([]byte, 2))
+	}
+	clientConn.Close()
+	<-done
+	return err
+}
+
+func TestServerConfigClientAuth(t *testing.T) {
+	ca, otherCA := newTestCA(t), newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	serverCert, serverKey := ca.issue(t, "server.test", false)
+	writeFile(t, certFile, serverCert)
+	writeFile(t, keyFile, serverKey)
+	writeFile(t, caFile, ca.pem)
+
+	clientCert := func(ca *testCA) []tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "client", true)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []tls.Certificate{cert}
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name       string
+		clientAuth string
+		want       tls.ClientAuthType
+		// handshake results without a certificate, with one from the
+		// client CA and with one from another CA
+		withoutCert, withCert, otherCert bool
+	}{
+		{name: "default", clientAuth: "", want: tls.NoClientCert, withoutCert: true, withCert: true, otherCert: true},
+		{name: "none", clientAuth: ClientAuthNone, want: tls.NoClientCert, withoutCert: true, withCert: true, otherCert: true},
+		{name: "optional", clientAuth: ClientAuthOptional, want: tls.VerifyClientCertIfGiven, withoutCert: true, withCert: true},
+		{name: "require", clientAuth: ClientAuthRequire, want: tls.RequireAndVerifyClientCert, withCert: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := ServerConfig(Options{CertFile: certFile, KeyFile: keyFile, ClientAuth: tt.clientAuth, ClientCAFile: caFile}, logging.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ClientAuth != tt.want {
+				t.Errorf("ClientAuth = %v, want %v", cfg.ClientAuth, tt.want)
+			}
+
+			for _, c := range []struct {
+				name  string
+				certs []tls.Certificate
+				ok    bool
+			}{
+				{"without certificate", nil, tt.withoutCert},
+				{"with certificate", clientCert(ca), tt.withCert},
+				{"with certificate of another CA", clientCert(otherCA), tt.otherCert},
+			} {
+				err := handshake(t, cfg, &tls.Config{RootCAs: roots, ServerName: "server.test", Certificates: c.certs})
+				if (err == nil) != c.ok {
+					t.Errorf("%s: handshake error %v, want success %v", c.name, err, c.ok)
+				}
+			}
+		})
+	}
+
+	for _, opts := range []Options{
+		{ClientAuth: "always", ClientCAFile: caFile},
+		{ClientAuth: ClientAuthRequire},
+		{ClientAuth: ClientAuthOptional, ClientCAFile: filepath.Join(dir, "missing.pem")},
+		{ClientAuth: ClientAuthOptional, ClientCAFile: keyFile},
+		{MinVersion: "1.1"},
+		{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+	} {
+		opts.CertFile, opts.KeyFile = certFile, keyFile
+		if _, _, err := ServerConfig(opts, logging.Discard()); err == nil {
+			t.Errorf("ServerConfig(%+v) accepted", opts)
+		}
+	}
+}
+
+func TestPinnedClientConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert1, key1 := ca.issue(t, "one.test", false)
+	writeFile(t, certFile, cert1)
+	writeFile(t, keyFile, key1)
+
+	serverCfg, cert, err := ServerConfig(Options{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Nanosecond}, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// names do not matter
+	pinned := PinnedClientConfig(cert)
+	pinned.ServerName = "other.test"
+	if err := handshake(t, serverCfg, pinned); err != nil {
+		t.Errorf("pinned certificate refused: %v", err)
+	}
+
+	// a server with a different certificate, even from the same CA
+	cert2, key2 := ca.issue(t, "one.test", false)
+	other, err := tls.X509KeyPair(cert2, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(t, &tls.Config{Certificates: []tls.Certificate{other}}, PinnedClientConfig(cert)); err == nil {
+		t.Error("other certificate accepted")
+	}
+
+	// after a reload the new certificate is pinned
+	writeFile(t, certFile, cert2)
+	writeFile(t, keyFile, key2)
+	if err := handshake(t, serverCfg, PinnedClientConfig(cert)); err != nil {
+		t.Errorf("reloaded certificate refused: %v", err)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for in, want := range map[string]uint16{"": tls.VersionTLS12, "1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13} {
+		if got, err := ParseVersion(in); err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseVersion("1.0"); err == nil {
+		t.Error("TLS 1.0 accepted")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites([]string{" TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", ""})
+	if err != nil || len(ids) != 1 || ids[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("ParseCipherSuites = %v, %v", ids, err)
+	}
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("insecure suite accepted")
+	}
+}
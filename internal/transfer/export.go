@@ -0,0 +1,49 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// exportPageSize is the number of links read from the store at a time
+const exportPageSize = 500
+
+// Export writes all links of the store to w page by page, so memory use
+// does not depend on the number of links. Returns the number of links
+// written.
+func Export(ctx context.Context, store storage.URLStore, w io.Writer, format Format) (int, error) {
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	query := storage.ListQuery{Sort: storage.SortByCreated, Limit: exportPageSize}
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		page, err := store.ListLinks(query)
+		if err != nil {
+			return n, fmt.Errorf("failed to list links: %w", err)
+		}
+		for _, link := range page.Links {
+			if err := enc.encode(fromLink(link)); err != nil {
+				return n, fmt.Errorf("failed to write link %q: %w", link.ShortCode, err)
+			}
+			n++
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if err := enc.flush(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
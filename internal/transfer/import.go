@@ -0,0 +1,249 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// ConflictPolicy decides what Import does with a record whose short code
+// already exists with different data.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictFail      ConflictPolicy = "fail"
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unsupported conflict policy %q: use 'skip', 'overwrite' or 'fail'", s)
+	}
+}
+
+var ErrConflict = errors.New("short code already exists with different data")
+
+// Postgres stores short codes as VARCHAR(16)
+const maxShortCodeLen = 16
+
+// at most this many record errors are kept in a Report
+const maxReportedErrors = 100
+
+type ImportOptions struct {
+	Format     Format
+	OnConflict ConflictPolicy
+	// Check every record against the store without writing anything
+	DryRun bool
+}
+
+// Report counts what Import did, or would do in a dry run.
+type Report struct {
+	DryRun bool
+	Read   int
+	// New short codes
+	Created int
+	// Existing codes replaced by ConflictOverwrite
+	Overwritten int
+	// Existing codes with the same URL and settings
+	Unchanged int
+	// Existing codes kept by ConflictSkip
+	Skipped int
+	// Links imported as dedicated because their URL already had another
+	// code in the same domain; both codes keep working
+	Dedicated int
+	// Records which could not be decoded or failed validation
+	Invalid int
+	// The first problems, in file order
+	Errors []RecordError
+}
+
+func (r *Report) addError(line int, code string, err error) {
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, RecordError{Line: line, ShortCode: code, Err: err})
+	}
+}
+
+type RecordError struct {
+	Line      int
+	ShortCode string
+	Err       error
+}
+
+func (e RecordError) Error() string {
+	if e.ShortCode == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d (%s): %v", e.Line, e.ShortCode, e.Err)
+}
+
+func (e RecordError) Unwrap() error { return e.Err }
+
+// Import reads links from r and saves them to the store under their own
+// short codes. Records are processed one at a time, so the input may be
+// larger than memory. With ConflictFail the import stops at the first
+// conflict, leaving the records before it imported. A dry run does not
+// see conflicts between records of the same input.
+func Import(ctx context.Context, store storage.URLStore, r io.Reader, opts ImportOptions) (Report, error) {
+	report := Report{DryRun: opts.DryRun}
+	dec, err := newDecoder(r, opts.Format)
+	if err != nil {
+		return report, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		rec, err := dec.decode()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil && !errors.Is(err, errInvalidRecord) {
+			return report, fmt.Errorf("failed to read input: %w", err)
+		}
+		report.Read++
+		if err == nil {
+			err = validate(rec)
+		}
+		if err != nil {
+			report.Invalid++
+			report.addError(dec.line(), rec.ShortCode, err)
+			continue
+		}
+
+		if err := importLink(store, rec.link(), opts, &report); err != nil {
+			return report, RecordError{Line: dec.line(), ShortCode: rec.ShortCode, Err: err}
+		}
+	}
+}
+
+func importLink(store storage.URLStore, link storage.Link, opts ImportOptions, report *Report) error {
+	existing, err := store.GetLink(link.Domain, link.ShortCode)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		if err := save(store, link, opts.DryRun, report); err != nil {
+			return err
+		}
+		report.Created++
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check existing link: %w", err)
+	}
+
+	if sameLink(existing, link) {
+		report.Unchanged++
+		return nil
+	}
+
+	switch opts.OnConflict {
+	case ConflictSkip:
+		report.Skipped++
+		return nil
+	case ConflictOverwrite:
+		if err := replace(store, existing, link, opts.DryRun, report); err != nil {
+			return err
+		}
+		report.Overwritten++
+		return nil
+	default:
+		return ErrConflict
+	}
+}
+
+// save stores the link keeping its short code. A plain link whose URL
+// already has another code is saved as dedicated, SaveURL would fail with
+// storage.ErrDuplicateOriginURL otherwise.
+func save(store storage.URLStore, link storage.Link, dryRun bool, report *Report) error {
+	if err := markDedicated(store, &link, report); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if err := store.SaveURL(link); err != nil {
+		return fmt.Errorf("failed to save link: %w", err)
+	}
+	return nil
+}
+
+// markDedicated makes a plain link dedicated if its URL already has another
+// code.
+func markDedicated(store storage.URLStore, link *storage.Link, report *Report) error {
+	if !link.Plain() {
+		return nil
+	}
+	code, err := store.GetShortURL(link.Domain, link.OriginURL)
+	switch {
+	case err == nil && code != link.ShortCode:
+		link.Dedicated = true
+		report.Dedicated++
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		return fmt.Errorf("failed to check existing URL: %w", err)
+	}
+	return nil
+}
+
+// replace swaps the existing link for the imported one. Stores implementing
+// storage.Replacer do it in one step; with the others the old link is
+// deleted first and saved again if saving the new one fails.
+func replace(store storage.URLStore, existing, link storage.Link, dryRun bool, report *Report) error {
+	if dryRun {
+		return markDedicated(store, &link, report)
+	}
+
+	if replacer, ok := store.(storage.Replacer); ok {
+		if err := markDedicated(store, &link, report); err != nil {
+			return err
+		}
+		if err := replacer.ReplaceURL(link); err != nil {
+			return fmt.Errorf("failed to replace link: %w", err)
+		}
+		return nil
+	}
+
+	if err := store.DeleteURL(existing.Domain, existing.ShortCode); err != nil {
+		return fmt.Errorf("failed to remove existing link: %w", err)
+	}
+	if err := save(store, link, false, report); err != nil {
+		// no transaction to roll back, put the old link back
+		_ = store.SaveURL(existing)
+		return err
+	}
+	return nil
+}
+
+// sameLink compares what a link does and shows, ignoring counters and
+// timestamps which change during normal operation.
+func sameLink(a, b storage.Link) bool {
+	return a.OriginURL == b.OriginURL &&
+		a.Owner == b.Owner &&
+		a.PasswordHash == b.PasswordHash &&
+		a.Interstitial == b.Interstitial &&
+		a.Title == b.Title &&
+		a.Description == b.Description &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+func validate(rec record) error {
+	if rec.ShortCode == "" {
+		return fmt.Errorf("%w: empty short_code", errInvalidRecord)
+	}
+	if len(rec.ShortCode) > maxShortCodeLen {
+		return fmt.Errorf("%w: short_code longer than %d characters", errInvalidRecord, maxShortCodeLen)
+	}
+	u, err := url.Parse(rec.OriginURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: origin_url is not an absolute http(s) URL", errInvalidRecord)
+	}
+	return nil
+}
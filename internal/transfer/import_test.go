@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+// conflictInput changes code1, adds code2 and repeats same1.
+const conflictInput = "short_code,origin_url,title\n" +
+	"same1,https://example.com/same,\n" +
+	"code1,https://example.com/new,New\n" +
+	"code2,https://example.com/2,\n"
+
+func conflictStore(t *testing.T) (*storage.InMemoryStore, storage.Link) {
+	t.Helper()
+	old := storage.Link{ShortCode: "code1", OriginURL: "https://example.com/old", CreatedAt: baseTime}
+	return newStore(t, old, storage.Link{ShortCode: "same1", OriginURL: "https://example.com/same", CreatedAt: baseTime}), old
+}
+
+func TestImportConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  ImportOptions
+		want  Report
+		err   error
+		code1 string // origin of code1 afterwards
+		code2 bool   // code2 was saved
+	}{
+		{name: "skip", opts: ImportOptions{OnConflict: ConflictSkip},
+			want:  Report{Read: 3, Created: 1, Unchanged: 1, Skipped: 1},
+			code1: "https://example.com/old", code2: true},
+		{name: "overwrite", opts: ImportOptions{OnConflict: ConflictOverwrite},
+			want:  Report{Read: 3, Created: 1, Unchanged: 1, Overwritten: 1},
+			code1: "https://example.com/new", code2: true},
+		{name: "fail", opts: ImportOptions{OnConflict: ConflictFail},
+			want: Report{Read: 2, Unchanged: 1}, err: ErrConflict,
+			code1: "https://example.com/old"},
+		{name: "dry run skip", opts: ImportOptions{OnConflict: ConflictSkip, DryRun: true},
+			want:  Report{DryRun: true, Read: 3, Created: 1, Unchanged: 1, Skipped: 1},
+			code1: "https://example.com/old"},
+		{name: "dry run overwrite", opts: ImportOptions{OnConflict: ConflictOverwrite, DryRun: true},
+			want:  Report{DryRun: true, Read: 3, Created: 1, Unchanged: 1, Overwritten: 1},
+			code1: "https://example.com/old"},
+		{name: "dry run fail", opts: ImportOptions{OnConflict: ConflictFail, DryRun: true},
+			want: Report{DryRun: true, Read: 2, Unchanged: 1}, err: ErrConflict,
+			code1: "https://example.com/old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := conflictStore(t)
+			tt.opts.Format = FormatCSV
+			report, err := Import(context.Background(), store, strings.NewReader(conflictInput), tt.opts)
+			if !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			var recErr RecordError
+			if err != nil && (!errors.As(err, &recErr) || recErr.Line != 3 || recErr.ShortCode != "code1") {
+				t.Errorf("err = %#v, want line 3 of code1", err)
+			}
+			if report.Read != tt.want.Read || report.Created != tt.want.Created || report.Overwritten != tt.want.Overwritten ||
+				report.Unchanged != tt.want.Unchanged || report.Skipped != tt.want.Skipped || report.DryRun != tt.want.DryRun {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+
+			if origin, err := store.GetOriginURL("", "code1"); err != nil || origin != tt.code1 {
+				t.Errorf("code1 -> %q, %v; want %q", origin, err, tt.code1)
+			}
+			if _, err := store.GetLink("", "code2"); (err == nil) != tt.code2 {
+				t.Errorf("code2: err = %v, want saved %t", err, tt.code2)
+			}
+		})
+	}
+}
+
+func TestImportDedicated(t *testing.T) {
+	existing := storage.Link{ShortCode: "old1", OriginURL: "https://example.com/a", CreatedAt: baseTime}
+	store := newStore(t, existing)
+	input := "short_code,origin_url,password_hash\n" +
+		"new1,https://example.com/a,\n" +
+		"new2,https://example.com/a,hash\n" +
+		"new3,https://example.com/b,\n"
+
+	report, err := Import(context.Background(), store, strings.NewReader(input), ImportOptions{Format: FormatCSV, OnConflict: ConflictFail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 3 || report.Dedicated != 1 {
+		t.Errorf("report = %+v, want 3 created, 1 dedicated", report)
+	}
+	for code, dedicated := range map[string]bool{"new1": true, "new2": false, "new3": false} {
+		if link, err := store.GetLink("", code); err != nil || link.Dedicated != dedicated {
+			t.Errorf("%s: dedicated %t, %v; want %t", code, link.Dedicated, err, dedicated)
+		}
+	}
+	// the existing code keeps the plain mapping
+	if code, err := store.GetShortURL("", existing.OriginURL); err != nil || code != existing.ShortCode {
+		t.Errorf("GetShortURL = %q, %v; want %q", code, err, existing.ShortCode)
+	}
+
+	// a dry run counts the same without saving
+	store = newStore(t, existing)
+	report, err = Import(context.Background(), store, strings.NewReader(input), ImportOptions{Format: FormatCSV, OnConflict: ConflictFail, DryRun: true})
+	if err != nil || report.Created != 3 || report.Dedicated != 1 {
+		t.Errorf("dry run: %+v, %v", report, err)
+	}
+	if _, err := store.GetLink("", "new1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("dry run saved new1: %v", err)
+	}
+}
+
+func TestImportOverwriteDedicated(t *testing.T) {
+	// code1 is overwritten with the URL which plain1 already has
+	store := newStore(t,
+		storage.Link{ShortCode: "plain1", OriginURL: "https://example.com/a", CreatedAt: baseTime},
+		storage.Link{ShortCode: "code1", OriginURL: "https://example.com/b", CreatedAt: baseTime},
+	)
+	input := "short_code,origin_url\ncode1,https://example.com/a\n"
+	report, err := Import(context.Background(), store, strings.NewReader(input), ImportOptions{Format: FormatCSV, OnConflict: ConflictOverwrite})
+	if err != nil || report.Overwritten != 1 || report.Dedicated != 1 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	if link, _ := store.GetLink("", "code1"); link.OriginURL != "https://example.com/a" || !link.Dedicated {
+		t.Errorf("code1 = %+v", link)
+	}
+	if code, _ := store.GetShortURL("", "https://example.com/b"); code != "" {
+		t.Errorf("old URL still maps to %q", code)
+	}
+}
+
+// noReplacer hides storage.Replacer of the store, so Import falls back to
+// delete and save. SaveURL of failCode fails once.
+type noReplacer struct {
+	storage.URLStore
+	failCode string
+}
+
+var errSaveFailed = errors.New("save failed")
+
+func (s *noReplacer) SaveURL(link storage.Link) error {
+	if link.ShortCode == s.failCode {
+		s.failCode = ""
+		return errSaveFailed
+	}
+	return s.URLStore.SaveURL(link)
+}
+
+func TestImportOverwriteWithoutReplacer(t *testing.T) {
+	mem, old := conflictStore(t)
+	store := &noReplacer{URLStore: mem}
+	if _, ok := storage.URLStore(store).(storage.Replacer); ok {
+		t.Fatal("noReplacer implements storage.Replacer")
+	}
+
+	report, err := Import(context.Background(), store, strings.NewReader(conflictInput), ImportOptions{Format: FormatCSV, OnConflict: ConflictOverwrite})
+	if err != nil || report.Overwritten != 1 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	if link, err := store.GetLink("", "code1"); err != nil || link.OriginURL != "https://example.com/new" || link.Title != "New" {
+		t.Errorf("code1 = %+v, %v", link, err)
+	}
+
+	// a failed save puts the old link back
+	mem, old = conflictStore(t)
+	store = &noReplacer{URLStore: mem, failCode: "code1"}
+	report, err = Import(context.Background(), store, strings.NewReader(conflictInput), ImportOptions{Format: FormatCSV, OnConflict: ConflictOverwrite})
+	if !errors.Is(err, errSaveFailed) || report.Overwritten != 0 {
+		t.Fatalf("report = %+v, err = %v; want %v", report, err, errSaveFailed)
+	}
+	checkLink(t, store, old)
+}
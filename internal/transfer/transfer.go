@@ -0,0 +1,267 @@
+// Package transfer exports links from a storage.URLStore to CSV or JSON
+// Lines and imports them back, keeping the short codes.
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatCSV, FormatNDJSON:
+		return f, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: use 'csv' or 'ndjson'", s)
+	}
+}
+
+// record is the file representation of a storage.Link. Domain is the
+// storage key, the empty string stands for the default domain.
+type record struct {
+	Domain       string    `json:"domain"`
+	ShortCode    string    `json:"short_code"`
+	OriginURL    string    `json:"origin_url"`
+	Owner        string    `json:"owner,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Clicks       int64     `json:"clicks,omitempty"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Interstitial bool      `json:"interstitial,omitempty"`
+	Dedicated    bool      `json:"dedicated,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+}
+
+func fromLink(link storage.Link) record {
+	return record{
+		Domain:       link.Domain,
+		ShortCode:    link.ShortCode,
+		OriginURL:    link.OriginURL,
+		Owner:        link.Owner,
+		CreatedAt:    link.CreatedAt.UTC(),
+		Clicks:       link.Clicks,
+		PasswordHash: link.PasswordHash,
+		Interstitial: link.Interstitial,
+		Dedicated:    link.Dedicated,
+		Title:        link.Title,
+		Description:  link.Description,
+		Tags:         link.Tags,
+	}
+}
+
+func (r record) link() storage.Link {
+	return storage.Link{
+		Domain:       r.Domain,
+		ShortCode:    r.ShortCode,
+		OriginURL:    r.OriginURL,
+		Owner:        r.Owner,
+		CreatedAt:    r.CreatedAt,
+		Clicks:       r.Clicks,
+		PasswordHash: r.PasswordHash,
+		Interstitial: r.Interstitial,
+		Dedicated:    r.Dedicated,
+		Metadata: storage.Metadata{
+			Title:       r.Title,
+			Description: r.Description,
+			Tags:        r.Tags,
+		},
+	}
+}
+
+// csvColumns is the CSV header written on export. On import only
+// short_code and origin_url are required and columns may come in any
+// order, so files of other shorteners can be imported after renaming
+// their header.
+var csvColumns = []string{
+	"domain", "short_code", "origin_url", "owner", "created_at", "clicks",
+	"password_hash", "interstitial", "dedicated", "title", "description", "tags",
+}
+
+// tags are joined with commas, which tags cannot contain
+const tagSeparator = ","
+
+type encoder interface {
+	encode(record) error
+	flush() error
+}
+
+func newEncoder(w io.Writer, format Format) (encoder, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	case FormatNDJSON:
+		return &jsonEncoder{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) encode(r record) error {
+	return e.w.Write([]string{
+		r.Domain, r.ShortCode, r.OriginURL, r.Owner, r.CreatedAt.Format(time.RFC3339Nano),
+		strconv.FormatInt(r.Clicks, 10), r.PasswordHash, strconv.FormatBool(r.Interstitial),
+		strconv.FormatBool(r.Dedicated), r.Title, r.Description, strings.Join(r.Tags, tagSeparator),
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) encode(r record) error { return e.enc.Encode(r) }
+func (e *jsonEncoder) flush() error          { return nil }
+
+// errInvalidRecord marks decode errors of a single record. Any other
+// decode error means the rest of the stream cannot be read.
+var errInvalidRecord = errors.New("invalid record")
+
+// decoder returns io.EOF after the last record.
+type decoder interface {
+	decode() (record, error)
+	// line of the last decoded record, for error reports
+	line() int
+}
+
+func newDecoder(r io.Reader, format Format) (decoder, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.ReuseRecord = true
+		cr.FieldsPerRecord = -1
+		header, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("empty CSV file")
+			}
+			return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, required := range []string{"short_code", "origin_url"} {
+			if _, ok := columns[required]; !ok {
+				return nil, fmt.Errorf("CSV header has no %q column", required)
+			}
+		}
+		return &csvDecoder{r: cr, columns: columns}, nil
+	case FormatNDJSON:
+		return &jsonDecoder{dec: json.NewDecoder(r)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+	n       int
+}
+
+func (d *csvDecoder) decode() (record, error) {
+	fields, err := d.r.Read()
+	if err != nil {
+		return record{}, err
+	}
+	d.n, _ = d.r.FieldPos(0)
+
+	get := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	r := record{
+		Domain:       get("domain"),
+		ShortCode:    get("short_code"),
+		OriginURL:    get("origin_url"),
+		Owner:        get("owner"),
+		PasswordHash: get("password_hash"),
+		Title:        get("title"),
+		Description:  get("description"),
+	}
+	if v := get("created_at"); v != "" {
+		if r.CreatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return r, fmt.Errorf("%w: created_at: %v", errInvalidRecord, err)
+		}
+	}
+	if v := get("clicks"); v != "" {
+		if r.Clicks, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return r, fmt.Errorf("%w: clicks: %v", errInvalidRecord, err)
+		}
+	}
+	if v := get("interstitial"); v != "" {
+		if r.Interstitial, err = strconv.ParseBool(v); err != nil {
+			return r, fmt.Errorf("%w: interstitial: %v", errInvalidRecord, err)
+		}
+	}
+	if v := get("dedicated"); v != "" {
+		if r.Dedicated, err = strconv.ParseBool(v); err != nil {
+			return r, fmt.Errorf("%w: dedicated: %v", errInvalidRecord, err)
+		}
+	}
+	if v := get("tags"); v != "" {
+		r.Tags = strings.Split(v, tagSeparator)
+	}
+	return r, nil
+}
+
+func (d *csvDecoder) line() int { return d.n }
+
+// jsonDecoder counts records as lines; blank lines are not counted.
+type jsonDecoder struct {
+	dec *json.Decoder
+	n   int
+}
+
+func (d *jsonDecoder) decode() (record, error) {
+	var r record
+	err := d.dec.Decode(&r)
+	if errors.Is(err, io.EOF) {
+		return r, err
+	}
+	d.n++
+	if err != nil {
+		// the decoder reads a whole value before unmarshalling it, so only
+		// syntax errors leave the stream out of sync
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return r, fmt.Errorf("line %d: %w", d.n, err)
+		}
+		return r, fmt.Errorf("%w: %v", errInvalidRecord, err)
+	}
+	return r, nil
+}
+
+func (d *jsonDecoder) line() int { return d.n }
@@ -0,0 +1,194 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+var baseTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newStore(t *testing.T, links ...storage.Link) *storage.InMemoryStore {
+	t.Helper()
+	store := storage.NewInMemoryStore(logging.Discard())
+	for _, link := range links {
+		if err := store.SaveURL(link); err != nil {
+			t.Fatalf("SaveURL(%q): %v", link.ShortCode, err)
+		}
+	}
+	return store
+}
+
+func checkLink(t *testing.T, store storage.URLStore, want storage.Link) {
+	t.Helper()
+	got, err := store.GetLink(want.Domain, want.ShortCode)
+	if err != nil {
+		t.Fatalf("GetLink(%q, %q): %v", want.Domain, want.ShortCode, err)
+	}
+	if !sameLink(got, want) || got.Clicks != want.Clicks || got.Dedicated != want.Dedicated || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("link = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"csv": FormatCSV, "CSV": FormatCSV, "ndjson": FormatNDJSON, "jsonl": FormatNDJSON} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) succeeded")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	links := []storage.Link{
+		{ShortCode: "plain1", OriginURL: "https://example.com/a?q=1,2", CreatedAt: baseTime, Clicks: 7, Owner: "key1"},
+		{ShortCode: "secret", OriginURL: "https://example.com/b", CreatedAt: baseTime.Add(time.Second),
+			PasswordHash: "$2a$10$hash", Interstitial: true},
+		{ShortCode: "meta12", OriginURL: "https://example.com/a?q=1,2", CreatedAt: baseTime.Add(2 * time.Second), Dedicated: true,
+			Metadata: storage.Metadata{Title: `Say "hi"`, Description: "line one\nline two", Tags: []string{"go", "news"}}},
+		{Domain: "go.example", ShortCode: "plain1", OriginURL: "https://example.com/c", CreatedAt: baseTime.Add(3 * time.Second)},
+	}
+
+	for _, format := range []Format{FormatCSV, FormatNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			var exported bytes.Buffer
+			n, err := Export(ctx, newStore(t, links...), &exported, format)
+			if err != nil || n != len(links) {
+				t.Fatalf("Export = %d, %v", n, err)
+			}
+
+			store := newStore(t)
+			report, err := Import(ctx, store, bytes.NewReader(exported.Bytes()), ImportOptions{Format: format, OnConflict: ConflictFail})
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if report.Read != len(links) || report.Created != len(links) || report.Dedicated != 0 || report.Invalid != 0 {
+				t.Errorf("report = %+v", report)
+			}
+			for _, link := range links {
+				checkLink(t, store, link)
+			}
+
+			var again bytes.Buffer
+			if _, err := Export(ctx, store, &again, format); err != nil {
+				t.Fatal(err)
+			}
+			if again.String() != exported.String() {
+				t.Errorf("export after import differs:\n%s\nwant\n%s", again.String(), exported.String())
+			}
+
+			// importing the same file again changes nothing
+			report, err = Import(ctx, store, bytes.NewReader(exported.Bytes()), ImportOptions{Format: format, OnConflict: ConflictFail})
+			if err != nil || report.Unchanged != len(links) {
+				t.Errorf("second import: %+v, %v", report, err)
+			}
+		})
+	}
+}
+
+func TestExportPages(t *testing.T) {
+	store := newStore(t)
+	const total = exportPageSize*2 + 1
+	for i := range total {
+		link := storage.Link{ShortCode: fmt.Sprintf("c%d", i), OriginURL: fmt.Sprintf("https://example.com/%d", i), CreatedAt: baseTime}
+		if err := store.SaveURL(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	n, err := Export(context.Background(), store, &out, FormatNDJSON)
+	if err != nil || n != total {
+		t.Fatalf("Export = %d, %v; want %d", n, err, total)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != total || len(slices.Compact(slices.Sorted(slices.Values(lines)))) != total {
+		t.Errorf("exported %d lines, want %d different ones", len(lines), total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Export(ctx, store, &out, FormatCSV); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled export: err = %v", err)
+	}
+}
+
+func TestImportInvalidRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		input   string
+		invalid []int // lines of the invalid records
+	}{
+		{name: "csv", format: FormatCSV, input: "origin_url,short_code,clicks,interstitial\n" +
+			"https://example.com/1,ok1,,\n" +
+			"https://example.com/2,,,\n" +
+			"https://example.com/3,toolongtoolongtoolong,,\n" +
+			"example.com/4,bad4,,\n" +
+			"https://example.com/5,bad5,many,\n" +
+			"https://example.com/6,bad6,,maybe\n" +
+			"https://example.com/7,ok7,3,true\n",
+			invalid: []int{3, 4, 5, 6, 7}},
+		{name: "ndjson", format: FormatNDJSON, input: `{"short_code":"ok1","origin_url":"https://example.com/1"}
+{"short_code":"bad2","origin_url":"ftp://example.com/2"}
+{"short_code":"bad3","origin_url":"https://example.com/3","clicks":"many"}
+{"short_code":"ok7","origin_url":"https://example.com/7"}
+`, invalid: []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(t)
+			report, err := Import(context.Background(), store, strings.NewReader(tt.input), ImportOptions{Format: tt.format, OnConflict: ConflictFail})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lines []int
+			for _, e := range report.Errors {
+				if !errors.Is(e, errInvalidRecord) {
+					t.Errorf("%v is not an invalid record", e)
+				}
+				lines = append(lines, e.Line)
+			}
+			if report.Created != 2 || report.Invalid != len(tt.invalid) || !slices.Equal(lines, tt.invalid) {
+				t.Errorf("report = %+v, want invalid lines %v", report, tt.invalid)
+			}
+			if _, err := store.GetLink("", "ok7"); err != nil {
+				t.Errorf("record after the invalid ones: %v", err)
+			}
+		})
+	}
+}
+
+func TestImportUnreadableInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   string
+	}{
+		{"empty csv", FormatCSV, "", "empty CSV file"},
+		{"csv without origin_url", FormatCSV, "short_code,url\n", `no "origin_url" column`},
+		{"broken csv", FormatCSV, "short_code,origin_url\nabc,\"https://example.com\n", "failed to read input"},
+		{"broken ndjson", FormatNDJSON, "{\"short_code\":\"abc\",\n", "failed to read input"},
+		{"unknown format", "xml", "", "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(context.Background(), newStore(t), strings.NewReader(tt.input), ImportOptions{Format: tt.format})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
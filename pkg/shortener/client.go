@@ -0,0 +1,149 @@
+// Package shortener is a Go client for the URL shortener service.
+//
+// NewGRPC and NewHTTP talk to the gRPC and HTTP APIs of a running server
+// behind the same Client interface; NewFake keeps links in memory for
+// tests of code which uses a Client.
+//
+//	c, err := shortener.NewGRPC("localhost:6969", shortener.Options{APIKey: key})
+//	if err != nil { ... }
+//	defer c.Close()
+//	code, err := c.Shorten(ctx, shortener.ShortenRequest{URL: "https://example.com"})
+//
+// NewGRPC connects in plaintext unless it is given dial options. For a
+// server with TLS pass transport credentials, with a client certificate if
+// the server asks for one (-grpc-client-auth):
+//
+//	creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{clientCert}})
+//	c, err := shortener.NewGRPC("sho.rt:6969", opts, grpc.WithTransportCredentials(creds))
+//
+// Calls whose context has no deadline get Options.Timeout. Idempotent
+// calls (Resolve, GetLink, List) are retried with exponential backoff when
+// the server is unavailable or rate limits the client. Resolve and GetLink
+// with a password are not retried, since every attempt counts against the
+// server's limit of password attempts.
+package shortener
+
+import (
+	"context"
+	"time"
+)
+
+// Domain arguments name a short domain served by the server; the empty
+// string means its default domain.
+type Client interface {
+	// Shorten creates a short link, or returns the existing code of a
+	// plain link for the same URL. Returns the short code.
+	Shorten(ctx context.Context, req ShortenRequest) (string, error)
+	// Resolve returns the original URL of a short code. password is only
+	// needed for password protected links.
+	Resolve(ctx context.Context, domain, code, password string) (string, error)
+	// GetLink returns the details of a short code.
+	GetLink(ctx context.Context, domain, code, password string) (Link, error)
+	// UpdateLink changes link metadata. Requires the API key which
+	// created the link.
+	UpdateLink(ctx context.Context, domain, code string, update LinkUpdate) (Link, error)
+	// Delete removes a short link. Requires the API key which created
+	// the link.
+	Delete(ctx context.Context, domain, code string) error
+	// List returns a page of the links of the API key, or of every owner
+	// for admin keys.
+	List(ctx context.Context, opts ListOptions) (Page, error)
+	Close() error
+}
+
+type ShortenRequest struct {
+	URL    string
+	Domain string
+	// Protects the link with a password
+	Password string
+	// Always show a preview page before redirecting
+	Interstitial bool
+	Title        string
+	Description  string
+	Tags         []string
+}
+
+type Link struct {
+	Domain   string
+	Code     string
+	ShortURL string
+	// Empty for password protected links of other owners in List
+	OriginURL   string
+	Title       string
+	Description string
+	Tags        []string
+	// API key ID which created the link
+	Owner        string
+	CreatedAt    time.Time
+	Clicks       int64
+	Protected    bool
+	Interstitial bool
+}
+
+// LinkUpdate changes the metadata of a link. Nil fields are left as is.
+type LinkUpdate struct {
+	Title       *string
+	Description *string
+	Tags        *[]string
+}
+
+type ListOptions struct {
+	// Substring of the name of the short domain the links belong to, not
+	// of the host of their original URLs
+	ShortDomain string
+	// API key ID which created the links, the client's key if empty.
+	// Only admin keys may list other owners' links, or every link with an
+	// empty Owner.
+	Owner string
+	Tag   string
+	// "created" (default) or "clicks", newest or most clicked first
+	Sort string
+	// Page size, the server default if zero
+	Limit int
+	// NextCursor of the previous page
+	Cursor string
+}
+
+type Page struct {
+	Links []Link
+	// Empty on the last page
+	NextCursor string
+}
+
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+)
+
+type Options struct {
+	// Sent with every call; needed for Shorten, UpdateLink, Delete and List
+	APIKey string
+	// Deadline of calls whose context has none, including retries.
+	// DefaultTimeout if zero.
+	Timeout time.Duration
+	// Attempts of idempotent calls, DefaultMaxAttempts if zero; 1 disables
+	// retries
+	MaxAttempts int
+	// Delay before the first retry, doubled for every further one up to
+	// MaxBackoff. DefaultBackoff and DefaultMaxBackoff if zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	return o
+}
@@ -0,0 +1,48 @@
+package shortener
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned by Client methods, usually wrapped in *Error. Check
+// them with errors.Is.
+var (
+	// The short code does not exist
+	ErrNotFound = errors.New("short URL not found")
+	// The short code already exists for a different URL
+	ErrDuplicateShortCode = errors.New("short code already exists for a different URL")
+	// The link is password protected and no password was given
+	ErrPasswordRequired = errors.New("short URL is password protected")
+	ErrInvalidPassword  = errors.New("invalid password")
+	// Missing or invalid API key
+	ErrUnauthenticated = errors.New("valid API key required")
+	// The API key does not own the link, or lists other owners' links
+	// without being an admin key
+	ErrForbidden       = errors.New("operation not allowed for this API key")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrRateLimited     = errors.New("rate limited")
+	// The server could not be reached or failed temporarily
+	ErrUnavailable = errors.New("service unavailable")
+	// The call is not supported by the API in use
+	ErrUnsupported = errors.New("not supported")
+)
+
+// Error is a failed call. It unwraps to one of the Err values, or to the
+// underlying error for unexpected failures.
+type Error struct {
+	Err error
+	// Message from the server, if any
+	Message string
+	// How long the server asked to wait before retrying, if it did
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" || e.Message == e.Err.Error() {
+		return "shortener: " + e.Err.Error()
+	}
+	return "shortener: " + e.Err.Error() + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
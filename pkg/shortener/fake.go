@@ -0,0 +1,243 @@
+package shortener
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Client for tests. It follows the server's rules
+// closely enough for code under test: plain links are deduplicated per
+// domain, passwords are checked and errors are the same *Error values the
+// real clients return. It does not know API keys; every link is owned by
+// Owner.
+type Fake struct {
+	// Owner reported for the links
+	Owner string
+
+	mu    sync.Mutex
+	links map[fakeKey]*fakeLink
+	seq   int
+}
+
+type fakeKey struct{ domain, code string }
+
+type fakeLink struct {
+	Link
+	password string
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{Owner: "fake", links: map[fakeKey]*fakeLink{}}
+}
+
+var _ Client = (*Fake)(nil)
+
+func (f *Fake) Close() error { return nil }
+
+func (f *Fake) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", &Error{Err: ErrInvalidArgument, Message: "invalid URL"}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	plain := req.Password == "" && !req.Interstitial && req.Title == "" && req.Description == "" && len(req.Tags) == 0
+	if plain {
+		for key, l := range f.links {
+			if key.domain == req.Domain && l.OriginURL == req.URL && l.password == "" &&
+				!l.Interstitial && l.Title == "" && l.Description == "" && len(l.Tags) == 0 {
+				return key.code, nil
+			}
+		}
+	}
+
+	f.seq++
+	code := "f" + strconv.FormatInt(int64(f.seq), 36)
+	f.links[fakeKey{req.Domain, code}] = &fakeLink{
+		Link: Link{
+			Domain:       req.Domain,
+			Code:         code,
+			ShortURL:     shortURL(req.Domain, code),
+			OriginURL:    req.URL,
+			Title:        req.Title,
+			Description:  req.Description,
+			Tags:         normalizeTags(req.Tags),
+			Owner:        f.Owner,
+			CreatedAt:    time.Now().UTC(),
+			Protected:    req.Password != "",
+			Interstitial: req.Interstitial,
+		},
+		password: req.Password,
+	}
+	return code, nil
+}
+
+func (f *Fake) Resolve(ctx context.Context, domain, code, password string) (string, error) {
+	link, err := f.GetLink(ctx, domain, code, password)
+	return link.OriginURL, err
+}
+
+func (f *Fake) GetLink(ctx context.Context, domain, code, password string) (Link, error) {
+	if err := ctx.Err(); err != nil {
+		return Link{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.links[fakeKey{domain, code}]
+	switch {
+	case !ok:
+		return Link{}, &Error{Err: ErrNotFound}
+	case l.password != "" && password == "":
+		return Link{}, &Error{Err: ErrPasswordRequired}
+	case l.password != "" && password != l.password:
+		return Link{}, &Error{Err: ErrInvalidPassword}
+	}
+	return l.clone(), nil
+}
+
+func (f *Fake) UpdateLink(ctx context.Context, domain, code string, update LinkUpdate) (Link, error) {
+	if err := ctx.Err(); err != nil {
+		return Link{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.links[fakeKey{domain, code}]
+	if !ok {
+		return Link{}, &Error{Err: ErrNotFound}
+	}
+	if update.Title != nil {
+		l.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.Description != nil {
+		l.Description = strings.TrimSpace(*update.Description)
+	}
+	if update.Tags != nil {
+		l.Tags = normalizeTags(*update.Tags)
+	}
+	return l.clone(), nil
+}
+
+func (f *Fake) Delete(ctx context.Context, domain, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.links[fakeKey{domain, code}]; !ok {
+		return &Error{Err: ErrNotFound}
+	}
+	delete(f.links, fakeKey{domain, code})
+	return nil
+}
+
+// List supports the same filters as the server. The cursor is the number
+// of links already returned.
+func (f *Fake) List(ctx context.Context, opts ListOptions) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	if opts.Sort != "" && opts.Sort != "created" && opts.Sort != "clicks" {
+		return Page{}, &Error{Err: ErrInvalidArgument, Message: "unknown sort order " + opts.Sort}
+	}
+	offset := 0
+	if opts.Cursor != "" {
+		n, err := strconv.Atoi(opts.Cursor)
+		if err != nil || n < 0 {
+			return Page{}, &Error{Err: ErrInvalidArgument, Message: "invalid cursor"}
+		}
+		offset = n
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	f.mu.Lock()
+	var links []Link
+	for _, l := range f.links {
+		if !strings.Contains(l.Domain, opts.ShortDomain) ||
+			(opts.Owner != "" && l.Owner != opts.Owner) ||
+			(opts.Tag != "" && !slices.Contains(l.Tags, strings.ToLower(opts.Tag))) {
+			continue
+		}
+		links = append(links, l.clone())
+	}
+	f.mu.Unlock()
+
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		if opts.Sort == "clicks" && a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if a.Domain != b.Domain {
+			return a.Domain > b.Domain
+		}
+		return a.Code > b.Code
+	})
+
+	var page Page
+	if offset < len(links) {
+		end := min(offset+limit, len(links))
+		page.Links = links[offset:end]
+		if end < len(links) {
+			page.NextCursor = strconv.Itoa(end)
+		}
+	}
+	return page, nil
+}
+
+// Click counts a visit of the link, as following it on the server would.
+func (f *Fake) Click(domain, code string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.links[fakeKey{domain, code}]
+	if !ok {
+		return &Error{Err: ErrNotFound}
+	}
+	l.Clicks++
+	return nil
+}
+
+func (l *fakeLink) clone() Link {
+	link := l.Link
+	link.Tags = slices.Clone(l.Tags)
+	return link
+}
+
+func shortURL(domain, code string) string {
+	if domain == "" {
+		return "/" + code
+	}
+	return "https://" + domain + "/" + code
+}
+
+// normalizeTags mirrors the server: lower case, sorted, without duplicates.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -0,0 +1,184 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFakeShorten(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+
+	code, err := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/a"}); err != nil || again != code {
+		t.Errorf("plain link again = %q, %v, want %q", again, err, code)
+	}
+	if other, _ := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/a", Domain: "go.example"}); other == code {
+		t.Errorf("other domain reused %q", code)
+	}
+	if titled, _ := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/a", Title: "A"}); titled == code {
+		t.Errorf("link with metadata reused %q", code)
+	}
+
+	for _, u := range []string{"", "example.com", "ftp://example.com", "https://"} {
+		if _, err := f.Shorten(ctx, ShortenRequest{URL: u}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Shorten(%q): err = %v, want %v", u, err, ErrInvalidArgument)
+		}
+	}
+
+	link, err := f.GetLink(ctx, "", code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ShortURL != "/"+code || link.Owner != "fake" || link.CreatedAt.IsZero() {
+		t.Errorf("link = %+v", link)
+	}
+}
+
+func TestFakePasswords(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	code, err := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/secret", Domain: "go.example", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		domain, code, password string
+		want                   error
+	}{
+		{"go.example", code, "", ErrPasswordRequired},
+		{"go.example", code, "wrong", ErrInvalidPassword},
+		{"go.example", "missing", "secret", ErrNotFound},
+		{"", code, "secret", ErrNotFound},
+	}
+	for _, tt := range tests {
+		_, err := f.Resolve(ctx, tt.domain, tt.code, tt.password)
+		var e *Error
+		if !errors.As(err, &e) || !errors.Is(err, tt.want) {
+			t.Errorf("Resolve(%q, %q, %q): err = %v, want %v", tt.domain, tt.code, tt.password, err, tt.want)
+		}
+	}
+
+	origin, err := f.Resolve(ctx, "go.example", code, "secret")
+	if err != nil || origin != "https://example.com/secret" {
+		t.Errorf("Resolve = %q, %v", origin, err)
+	}
+	if link, _ := f.GetLink(ctx, "go.example", code, "secret"); !link.Protected || link.ShortURL != "https://go.example/"+code {
+		t.Errorf("link = %+v", link)
+	}
+}
+
+func TestFakeUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	code, err := f.Shorten(ctx, ShortenRequest{URL: "https://example.com/a", Tags: []string{"B", "a", "b "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, _ := f.GetLink(ctx, "", code, "")
+	if !slices.Equal(link.Tags, []string{"a", "b"}) {
+		t.Errorf("tags = %q, want normalized", link.Tags)
+	}
+	// links returned are copies
+	link.Tags[0] = "changed"
+
+	title, tags := " Title ", []string{"Z"}
+	updated, err := f.UpdateLink(ctx, "", code, LinkUpdate{Title: &title, Tags: &tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "Title" || updated.Description != "" || !slices.Equal(updated.Tags, []string{"z"}) {
+		t.Errorf("updated = %+v", updated)
+	}
+	if _, err := f.UpdateLink(ctx, "", "missing", LinkUpdate{Title: &title}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update missing: err = %v", err)
+	}
+
+	if err := f.Delete(ctx, "", code); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Delete(ctx, "", code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete again: err = %v", err)
+	}
+	if _, err := f.GetLink(ctx, "", code, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted: err = %v", err)
+	}
+}
+
+func TestFakeList(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	var codes []string
+	for _, req := range []ShortenRequest{
+		{URL: "https://example.com/1", Tags: []string{"go"}},
+		{URL: "https://example.com/2", Domain: "go.example"},
+		{URL: "https://example.com/3", Tags: []string{"go"}},
+	} {
+		code, err := f.Shorten(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, code)
+	}
+	if err := f.Click("", codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Click("", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("click missing: err = %v", err)
+	}
+
+	list := func(opts ListOptions) ([]string, string) {
+		t.Helper()
+		page, err := f.List(ctx, opts)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", opts, err)
+		}
+		var got []string
+		for _, l := range page.Links {
+			got = append(got, l.Code)
+		}
+		return got, page.NextCursor
+	}
+
+	if got, _ := list(ListOptions{Tag: "GO", Sort: "clicks"}); !slices.Equal(got, []string{codes[0], codes[2]}) {
+		t.Errorf("by clicks = %q", got)
+	}
+	if got, _ := list(ListOptions{ShortDomain: "go."}); !slices.Equal(got, []string{codes[1]}) {
+		t.Errorf("by domain = %q", got)
+	}
+	if got, _ := list(ListOptions{Owner: "someone"}); len(got) != 0 {
+		t.Errorf("by other owner = %q", got)
+	}
+
+	first, cursor := list(ListOptions{Limit: 2})
+	rest, last := list(ListOptions{Limit: 2, Cursor: cursor})
+	if len(first) != 2 || cursor == "" || len(rest) != 1 || last != "" {
+		t.Errorf("pages %q (%q), %q (%q)", first, cursor, rest, last)
+	}
+	if all := append(first, rest...); len(slices.Compact(slices.Sorted(slices.Values(all)))) != 3 {
+		t.Errorf("pages repeat links: %q", all)
+	}
+
+	if _, err := f.List(ctx, ListOptions{Sort: "name"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("unknown sort: err = %v", err)
+	}
+	if _, err := f.List(ctx, ListOptions{Cursor: "x"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("invalid cursor: err = %v", err)
+	}
+}
+
+func TestFakeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := NewFake()
+	if _, err := f.Shorten(ctx, ShortenRequest{URL: "https://example.com"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want canceled", err)
+	}
+}
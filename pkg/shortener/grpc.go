@@ -0,0 +1,242 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	shortener_v0 "github.com/vadyaov/url_shortener/internal/app/grpc/pkg/shortener_v0"
+)
+
+// GRPCClient is a Client for the gRPC API.
+type GRPCClient struct {
+	conn *grpc.ClientConn
+	stub shortener_v0.ShortenerV0Client
+	opts Options
+}
+
+// NewGRPC connects lazily to the gRPC server at target. Without dial
+// options the connection is plaintext; dial options, when given, must
+// include transport credentials.
+func NewGRPC(target string, opts Options, dialOpts ...grpc.DialOption) (*GRPCClient, error) {
+	if len(dialOpts) == 0 {
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{
+		conn: conn,
+		stub: shortener_v0.NewShortenerV0Client(conn),
+		opts: opts.withDefaults(),
+	}, nil
+}
+
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// call passes fn the call options to use for the RPC, so that response
+// headers such as retry-after are seen.
+func (c *GRPCClient) call(ctx context.Context, idempotent bool, fn func(ctx context.Context, opts ...grpc.CallOption) error) error {
+	return call(ctx, c.opts, idempotent, func(ctx context.Context) error {
+		if c.opts.APIKey != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.opts.APIKey)
+		}
+		var header metadata.MD
+		err := fn(ctx, grpc.Header(&header))
+		return fromStatus(err, header)
+	})
+}
+
+func (c *GRPCClient) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	var code string
+	err := c.call(ctx, false, func(ctx context.Context, opts ...grpc.CallOption) error {
+		resp, err := c.stub.GetShortUrl(ctx, &shortener_v0.Request{
+			Url:          req.URL,
+			Domain:       req.Domain,
+			Password:     req.Password,
+			Interstitial: req.Interstitial,
+			Title:        req.Title,
+			Description:  req.Description,
+			Tags:         req.Tags,
+		}, opts...)
+		if err != nil {
+			return err
+		}
+		code = resp.GetUrl()
+		return nil
+	})
+	return code, err
+}
+
+func (c *GRPCClient) Resolve(ctx context.Context, domain, code, password string) (string, error) {
+	var origin string
+	err := c.call(ctx, password == "", func(ctx context.Context, opts ...grpc.CallOption) error {
+		resp, err := c.stub.ResolveUrl(ctx, &shortener_v0.Request{Url: code, Domain: domain, Password: password}, opts...)
+		if err != nil {
+			return err
+		}
+		origin = resp.GetUrl()
+		return nil
+	})
+	return origin, err
+}
+
+func (c *GRPCClient) GetLink(ctx context.Context, domain, code, password string) (Link, error) {
+	var link Link
+	err := c.call(ctx, password == "", func(ctx context.Context, opts ...grpc.CallOption) error {
+		resp, err := c.stub.GetLink(ctx, &shortener_v0.Request{Url: code, Domain: domain, Password: password}, opts...)
+		if err != nil {
+			return err
+		}
+		link = linkFromMessage(resp)
+		return nil
+	})
+	return link, err
+}
+
+func (c *GRPCClient) UpdateLink(ctx context.Context, domain, code string, update LinkUpdate) (Link, error) {
+	req := &shortener_v0.UpdateLinkRequest{
+		Url:         code,
+		Domain:      domain,
+		Title:       update.Title,
+		Description: update.Description,
+	}
+	if update.Tags != nil {
+		req.Tags = &shortener_v0.TagList{Values: *update.Tags}
+	}
+
+	var link Link
+	err := c.call(ctx, false, func(ctx context.Context, opts ...grpc.CallOption) error {
+		resp, err := c.stub.UpdateLink(ctx, req, opts...)
+		if err != nil {
+			return err
+		}
+		link = linkFromMessage(resp)
+		return nil
+	})
+	return link, err
+}
+
+func (c *GRPCClient) Delete(ctx context.Context, domain, code string) error {
+	return c.call(ctx, false, func(ctx context.Context, opts ...grpc.CallOption) error {
+		_, err := c.stub.DeleteShortUrl(ctx, &shortener_v0.Request{Url: code, Domain: domain}, opts...)
+		return err
+	})
+}
+
+func (c *GRPCClient) List(ctx context.Context, opts ListOptions) (Page, error) {
+	var page Page
+	err := c.call(ctx, true, func(ctx context.Context, callOpts ...grpc.CallOption) error {
+		resp, err := c.stub.ListLinks(ctx, &shortener_v0.ListLinksRequest{
+			ShortDomain: opts.ShortDomain,
+			Owner:       opts.Owner,
+			Tag:         opts.Tag,
+			Sort:        opts.Sort,
+			Limit:       int32(opts.Limit),
+			Cursor:      opts.Cursor,
+		}, callOpts...)
+		if err != nil {
+			return err
+		}
+		page = Page{Links: make([]Link, 0, len(resp.GetLinks())), NextCursor: resp.GetNextCursor()}
+		for _, link := range resp.GetLinks() {
+			page.Links = append(page.Links, linkFromMessage(link))
+		}
+		return nil
+	})
+	return page, err
+}
+
+func linkFromMessage(m *shortener_v0.Link) Link {
+	var created time.Time
+	if m.GetCreatedAt() != nil {
+		created = m.GetCreatedAt().AsTime()
+	}
+	return Link{
+		Domain:       m.GetDomain(),
+		Code:         m.GetShortCode(),
+		ShortURL:     m.GetShortUrl(),
+		OriginURL:    m.GetOriginUrl(),
+		Title:        m.GetTitle(),
+		Description:  m.GetDescription(),
+		Tags:         m.GetTags(),
+		Owner:        m.GetOwner(),
+		CreatedAt:    created,
+		Clicks:       m.GetClicks(),
+		Protected:    m.GetProtected(),
+		Interstitial: m.GetInterstitial(),
+	}
+}
+
+// reasons sent by the server with PermissionDenied statuses and 403
+// responses
+const (
+	reasonPasswordRequired = "PASSWORD_REQUIRED"
+	reasonInvalidPassword  = "INVALID_PASSWORD"
+)
+
+// fromStatus maps gRPC status errors to *Error.
+func fromStatus(err error, header metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return &Error{Err: err}
+	}
+
+	e := &Error{Message: st.Message()}
+	switch st.Code() {
+	case codes.NotFound:
+		e.Err = ErrNotFound
+	case codes.AlreadyExists:
+		e.Err = ErrDuplicateShortCode
+	case codes.InvalidArgument:
+		e.Err = ErrInvalidArgument
+	case codes.Unauthenticated:
+		e.Err = ErrUnauthenticated
+	case codes.PermissionDenied:
+		e.Err = ErrForbidden
+		for _, d := range st.Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok {
+				switch info.GetReason() {
+				case reasonPasswordRequired:
+					e.Err = ErrPasswordRequired
+				case reasonInvalidPassword:
+					e.Err = ErrInvalidPassword
+				}
+			}
+		}
+	case codes.ResourceExhausted:
+		e.Err = ErrRateLimited
+		if vals := header.Get("retry-after"); len(vals) > 0 {
+			if secs, err := strconv.Atoi(vals[0]); err == nil {
+				e.RetryAfter = time.Duration(secs) * time.Second
+			}
+		}
+	case codes.Unavailable:
+		e.Err = ErrUnavailable
+	case codes.Unimplemented:
+		e.Err = ErrUnsupported
+	case codes.DeadlineExceeded:
+		e.Err = context.DeadlineExceeded
+	case codes.Canceled:
+		e.Err = context.Canceled
+	default:
+		e.Err = errors.New(st.Code().String())
+	}
+	return e
+}
+
+var _ Client = (*GRPCClient)(nil)
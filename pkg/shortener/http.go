@@ -0,0 +1,317 @@
+package shortener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPClient is a Client for the REST API of the HTTP server. Password
+// protected links are read through the resolve endpoint, which does not
+// count a visit.
+type HTTPClient struct {
+	base *url.URL
+	hc   *http.Client
+	opts Options
+}
+
+const (
+	apiPrefix = "/api/v1"
+	// maximum size of a response body read by HTTPClient
+	maxResponseSize = 4 << 20
+)
+
+// NewHTTP returns a client for the server at baseURL, e.g.
+// "https://sho.rt". hc is used for requests, http.DefaultClient if nil;
+// redirects are never followed.
+func NewHTTP(baseURL string, opts Options, hc *http.Client) (*HTTPClient, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("base URL %q must be an absolute http(s) URL", baseURL)
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	noRedirect := *hc
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &HTTPClient{base: base, hc: &noRedirect, opts: opts.withDefaults()}, nil
+}
+
+func (c *HTTPClient) Close() error {
+	c.hc.CloseIdleConnections()
+	return nil
+}
+
+type errorResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+type listResponse struct {
+	Links      []linkJSON `json:"links"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+type linkJSON struct {
+	Domain       string    `json:"domain"`
+	ShortCode    string    `json:"short_code"`
+	ShortURL     string    `json:"short_url"`
+	OriginURL    string    `json:"origin_url"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Owner        string    `json:"owner"`
+	CreatedAt    time.Time `json:"created_at"`
+	Clicks       int64     `json:"clicks"`
+	Tags         []string  `json:"tags"`
+	Protected    bool      `json:"protected"`
+	Interstitial bool      `json:"interstitial"`
+}
+
+func (l linkJSON) link() Link {
+	return Link{
+		Domain:       l.Domain,
+		Code:         l.ShortCode,
+		ShortURL:     l.ShortURL,
+		OriginURL:    l.OriginURL,
+		Title:        l.Title,
+		Description:  l.Description,
+		Tags:         l.Tags,
+		Owner:        l.Owner,
+		CreatedAt:    l.CreatedAt,
+		Clicks:       l.Clicks,
+		Protected:    l.Protected,
+		Interstitial: l.Interstitial,
+	}
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// JSON request body
+	body any
+}
+
+// send performs one attempt and returns the response of a 2xx or 3xx
+// status. The caller closes the body.
+func (c *HTTPClient) send(ctx context.Context, r request) (*http.Response, error) {
+	u := c.base.JoinPath(r.path)
+	u.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, &Error{Err: err}
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.APIKey)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, &Error{Err: ctx.Err(), Message: err.Error()}
+		}
+		return nil, &Error{Err: ErrUnavailable, Message: err.Error()}
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, fromHTTPStatus(resp)
+	}
+	return resp, nil
+}
+
+// do performs the request with retries and decodes the JSON response into
+// out, unless it is nil.
+func (c *HTTPClient) do(ctx context.Context, idempotent bool, r request, out any) error {
+	return call(ctx, c.opts, idempotent, func(ctx context.Context) error {
+		resp, err := c.send(ctx, r)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if out == nil {
+			return nil
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out); err != nil {
+			return &Error{Err: fmt.Errorf("invalid response: %w", err)}
+		}
+		return nil
+	})
+}
+
+func fromHTTPStatus(resp *http.Response) error {
+	e := &Error{}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	var parsed errorResponse
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error != "" {
+		e.Message = parsed.Error
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		e.Err = ErrInvalidArgument
+	case http.StatusUnauthorized:
+		e.Err = ErrUnauthenticated
+	case http.StatusForbidden:
+		switch parsed.Reason {
+		case reasonPasswordRequired:
+			e.Err = ErrPasswordRequired
+		case reasonInvalidPassword:
+			e.Err = ErrInvalidPassword
+		default:
+			e.Err = ErrForbidden
+		}
+	case http.StatusNotFound:
+		e.Err = ErrNotFound
+	case http.StatusConflict:
+		e.Err = ErrDuplicateShortCode
+	case http.StatusTooManyRequests:
+		e.Err = ErrRateLimited
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		e.Err = ErrUnavailable
+	default:
+		e.Err = errors.New(resp.Status)
+	}
+	return e
+}
+
+// createRequest is the body of POST /api/v1/links.
+type createRequest struct {
+	URL          string   `json:"url"`
+	Domain       string   `json:"domain,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Interstitial bool     `json:"interstitial,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// resolveRequest is the body of POST /api/v1/links/{code}/resolve.
+type resolveRequest struct {
+	Password string `json:"password"`
+}
+
+// updateRequest is the body of PATCH /api/v1/links/{code}.
+type updateRequest struct {
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+}
+
+func (c *HTTPClient) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	var link linkJSON
+	err := c.do(ctx, false, request{method: http.MethodPost, path: apiPrefix + "/links", body: createRequest{
+		URL:          req.URL,
+		Domain:       req.Domain,
+		Password:     req.Password,
+		Interstitial: req.Interstitial,
+		Title:        req.Title,
+		Description:  req.Description,
+		Tags:         req.Tags,
+	}}, &link)
+	return link.ShortCode, err
+}
+
+func (c *HTTPClient) Resolve(ctx context.Context, domain, code, password string) (string, error) {
+	link, err := c.GetLink(ctx, domain, code, password)
+	return link.OriginURL, err
+}
+
+// GetLink with a password is not retried: every attempt counts against
+// the password attempt limit of the server.
+func (c *HTTPClient) GetLink(ctx context.Context, domain, code, password string) (Link, error) {
+	r := request{method: http.MethodGet, path: linkPath(code), query: domainQuery(domain)}
+	if password != "" {
+		r.method = http.MethodPost
+		r.path += "/resolve"
+		r.body = resolveRequest{Password: password}
+	}
+
+	var link linkJSON
+	err := c.do(ctx, password == "", r, &link)
+	return link.link(), err
+}
+
+func (c *HTTPClient) UpdateLink(ctx context.Context, domain, code string, update LinkUpdate) (Link, error) {
+	var link linkJSON
+	err := c.do(ctx, false, request{
+		method: http.MethodPatch,
+		path:   linkPath(code),
+		query:  domainQuery(domain),
+		body:   updateRequest(update),
+	}, &link)
+	return link.link(), err
+}
+
+func (c *HTTPClient) Delete(ctx context.Context, domain, code string) error {
+	return c.do(ctx, false, request{method: http.MethodDelete, path: linkPath(code), query: domainQuery(domain)}, nil)
+}
+
+func (c *HTTPClient) List(ctx context.Context, opts ListOptions) (Page, error) {
+	query := url.Values{}
+	setIf(query, "short_domain", opts.ShortDomain)
+	setIf(query, "owner", opts.Owner)
+	setIf(query, "tag", opts.Tag)
+	setIf(query, "sort", opts.Sort)
+	setIf(query, "cursor", opts.Cursor)
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var resp listResponse
+	if err := c.do(ctx, true, request{method: http.MethodGet, path: apiPrefix + "/links", query: query}, &resp); err != nil {
+		return Page{}, err
+	}
+	page := Page{Links: make([]Link, 0, len(resp.Links)), NextCursor: resp.NextCursor}
+	for _, l := range resp.Links {
+		page.Links = append(page.Links, l.link())
+	}
+	return page, nil
+}
+
+func linkPath(code string) string {
+	return apiPrefix + "/links/" + url.PathEscape(code)
+}
+
+func domainQuery(domain string) url.Values {
+	query := url.Values{}
+	setIf(query, "domain", domain)
+	return query
+}
+
+func setIf(values url.Values, key, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
+
+var _ Client = (*HTTPClient)(nil)
@@ -0,0 +1,215 @@
+package shortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	httphandlers "github.com/vadyaov/url_shortener/internal/handlers/http"
+	"github.com/vadyaov/url_shortener/internal/logging"
+	"github.com/vadyaov/url_shortener/internal/service"
+	"github.com/vadyaov/url_shortener/internal/storage"
+)
+
+func newTestHTTP(t *testing.T, handler http.Handler, opts Options) *HTTPClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := NewHTTP(srv.URL, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestHTTPErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		retryAfter string
+		want       error
+		message    string
+		wantRetry  time.Duration
+	}{
+		{name: "bad request", status: 400, body: `{"status":400,"error":"Incorrect or empty 'url' field"}`,
+			want: ErrInvalidArgument, message: "Incorrect or empty 'url' field"},
+		{name: "too large", status: 413, body: `{"status":413,"error":"too large"}`, want: ErrInvalidArgument},
+		{name: "unsupported media type", status: 415, body: `{"status":415,"error":"json"}`, want: ErrInvalidArgument},
+		{name: "unauthenticated", status: 401, body: `{"status":401,"error":"valid API key required"}`, want: ErrUnauthenticated},
+		{name: "forbidden", status: 403, body: `{"status":403,"error":"Only the owner can delete this short URL"}`, want: ErrForbidden},
+		{name: "forbidden mentioning a password", status: 403, body: `{"status":403,"error":"password of the owner"}`, want: ErrForbidden},
+		{name: "password required", status: 403, body: `{"status":403,"error":"protected","reason":"PASSWORD_REQUIRED"}`,
+			want: ErrPasswordRequired, message: "protected"},
+		{name: "invalid password", status: 403, body: `{"status":403,"error":"Wrong password","reason":"INVALID_PASSWORD"}`,
+			want: ErrInvalidPassword},
+		{name: "not found", status: 404, body: `{"status":404,"error":"Short URL not found"}`, want: ErrNotFound},
+		{name: "conflict", status: 409, body: `{"status":409,"error":"conflict"}`, want: ErrDuplicateShortCode},
+		{name: "rate limited", status: 429, body: `{"status":429,"error":"slow down"}`, retryAfter: "7",
+			want: ErrRateLimited, wantRetry: 7 * time.Second},
+		{name: "bad gateway", status: 502, body: "bad gateway", want: ErrUnavailable, message: "bad gateway"},
+		{name: "unavailable", status: 503, want: ErrUnavailable},
+		{name: "gateway timeout", status: 504, want: ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}), Options{MaxAttempts: 1})
+
+			_, err := c.GetLink(context.Background(), "", "abc", "")
+			var e *Error
+			if !errors.As(err, &e) || !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.message != "" && e.Message != tt.message {
+				t.Errorf("message = %q, want %q", e.Message, tt.message)
+			}
+			if e.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", e.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+
+	c := newTestHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), Options{MaxAttempts: 1})
+	_, err := c.GetLink(context.Background(), "", "abc", "")
+	var e *Error
+	if !errors.As(err, &e) || e.Err.Error() != "500 Internal Server Error" {
+		t.Errorf("err = %v, want the status", err)
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	requests := 0
+	c := newTestHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}), Options{MaxAttempts: 3, Backoff: time.Millisecond})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want int
+	}{
+		{name: "get link", call: func() error { _, err := c.GetLink(ctx, "", "abc", ""); return err }, want: 3},
+		{name: "list", call: func() error { _, err := c.List(ctx, ListOptions{}); return err }, want: 3},
+		{name: "get link with password", call: func() error { _, err := c.GetLink(ctx, "", "abc", "secret"); return err }, want: 1},
+		{name: "resolve with password", call: func() error { _, err := c.Resolve(ctx, "", "abc", "secret"); return err }, want: 1},
+		{name: "shorten", call: func() error { _, err := c.Shorten(ctx, ShortenRequest{URL: "https://example.com"}); return err }, want: 1},
+		{name: "delete", call: func() error { return c.Delete(ctx, "", "abc") }, want: 1},
+	}
+	for _, tt := range tests {
+		requests = 0
+		if err := tt.call(); !errors.Is(err, ErrUnavailable) {
+			t.Errorf("%s: err = %v, want unavailable", tt.name, err)
+		}
+		if requests != tt.want {
+			t.Errorf("%s: %d requests, want %d", tt.name, requests, tt.want)
+		}
+	}
+}
+
+func TestHTTPGetLinkWithPassword(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.Discard()
+	store := storage.NewInMemoryStore(logger)
+	clicks := service.NewClickRecorder(store, time.Hour, logger)
+	svc := service.NewUrlService(store, service.NewDomains("https", "sho.rt", "go.example"), service.Options{Clicks: clicks}, logger)
+	h := httphandlers.NewUrlHandler(svc, httphandlers.Options{}, logger)
+	mux := http.NewServeMux()
+	for _, route := range h.V1Routes() {
+		mux.Handle(route.Pattern, route.Handler)
+	}
+	c := newTestHTTP(t, mux, Options{})
+
+	code, err := svc.GetShortUrl(ctx, "https://example.com/secret", service.CreateOptions{
+		Domain:   "go.example",
+		Password: "secret",
+		Metadata: storage.Metadata{Title: "Secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetLink(ctx, "go.example", code, ""); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("without password: err = %v, want %v", err, ErrPasswordRequired)
+	}
+	if _, err := c.Resolve(ctx, "go.example", code, "wrong"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("wrong password: err = %v, want %v", err, ErrInvalidPassword)
+	}
+	if _, err := c.Resolve(ctx, "", code, "secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other domain: err = %v, want %v", err, ErrNotFound)
+	}
+
+	link, err := c.GetLink(ctx, "go.example", code, "secret")
+	if err != nil {
+		t.Fatalf("GetLink: %v", err)
+	}
+	if link.OriginURL != "https://example.com/secret" || link.Title != "Secret" || !link.Protected || link.ShortURL != "https://go.example/"+code {
+		t.Errorf("link = %+v", link)
+	}
+	origin, err := c.Resolve(ctx, "go.example", code, "secret")
+	if err != nil || origin != "https://example.com/secret" {
+		t.Errorf("Resolve = %q, %v", origin, err)
+	}
+
+	clicks.Flush()
+	info, err := svc.ResolveWithPassword(ctx, "go.example", code, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Clicks != 0 {
+		t.Errorf("clicks = %d, lookups must not count visits", info.Clicks)
+	}
+}
+
+func TestHTTPRequests(t *testing.T) {
+	type seen struct {
+		method, path, query, auth, contentType string
+		body                                   map[string]any
+	}
+	var got seen
+	c := newTestHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = seen{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.RawQuery,
+			auth: r.Header.Get("Authorization"), contentType: r.Header.Get("Content-Type")}
+		json.NewDecoder(r.Body).Decode(&got.body)
+		io.WriteString(w, `{"short_code":"abc","origin_url":"https://example.com"}`)
+	}), Options{APIKey: "usk_key"})
+	ctx := context.Background()
+
+	if _, err := c.GetLink(ctx, "go.example", "a/b", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "POST" || got.path != "/api/v1/links/a%2Fb/resolve" || got.query != "domain=go.example" ||
+		got.contentType != "application/json" || got.body["password"] != "secret" || got.auth != "Bearer usk_key" {
+		t.Errorf("GetLink with password sent %+v", got)
+	}
+
+	if _, err := c.GetLink(ctx, "", "abc", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "GET" || got.path != "/api/v1/links/abc" || got.query != "" || got.contentType != "" {
+		t.Errorf("GetLink sent %+v", got)
+	}
+
+	title := "T"
+	if _, err := c.UpdateLink(ctx, "", "abc", LinkUpdate{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "PATCH" || got.path != "/api/v1/links/abc" || len(got.body) != 1 || got.body["title"] != "T" {
+		t.Errorf("UpdateLink sent %+v", got)
+	}
+}
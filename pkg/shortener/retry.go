@@ -0,0 +1,58 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// withDeadline applies the default timeout to contexts without a deadline.
+func withDeadline(ctx context.Context, opts Options) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+// call runs fn under the call deadline. Idempotent calls are retried
+// after temporary failures while attempts and the deadline allow.
+func call(ctx context.Context, opts Options, idempotent bool, fn func(ctx context.Context) error) error {
+	ctx, cancel := withDeadline(ctx, opts)
+	defer cancel()
+
+	attempts := 1
+	if idempotent {
+		attempts = opts.MaxAttempts
+	}
+	backoff := opts.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		wait := backoff/2 + rand.N(backoff/2+1)
+		var e *Error
+		if errors.As(err, &e) && e.RetryAfter > wait {
+			wait = e.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited)
+}
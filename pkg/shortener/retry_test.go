@@ -0,0 +1,125 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failing returns fn which fails with errs in turn, then succeeds, and
+// the number of its calls.
+func failing(errs ...error) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestCallRetries(t *testing.T) {
+	opts := Options{MaxAttempts: 4, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}.withDefaults()
+	unavailable := &Error{Err: ErrUnavailable}
+	fn, calls := failing(unavailable, unavailable, unavailable)
+
+	start := time.Now()
+	if err := call(context.Background(), opts, true, fn); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("calls = %d, want 4", *calls)
+	}
+	// waits of 5-10ms, then 10-20ms twice as the backoff doubles up to MaxBackoff
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond || elapsed > time.Second {
+		t.Errorf("retried after %v, want 25-50ms of backoff", elapsed)
+	}
+}
+
+func TestCallStops(t *testing.T) {
+	opts := Options{MaxAttempts: 3, Backoff: time.Millisecond}.withDefaults()
+	unavailable := &Error{Err: ErrUnavailable}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		idempotent bool
+		errs       []error
+		wantCalls  int
+		want       error
+	}{
+		{name: "out of attempts", opts: opts, idempotent: true,
+			errs: []error{unavailable, unavailable, unavailable}, wantCalls: 3, want: ErrUnavailable},
+		{name: "not idempotent", opts: opts, errs: []error{unavailable}, wantCalls: 1, want: ErrUnavailable},
+		{name: "retries disabled", opts: Options{MaxAttempts: 1}.withDefaults(), idempotent: true,
+			errs: []error{unavailable}, wantCalls: 1, want: ErrUnavailable},
+		{name: "not retryable", opts: opts, idempotent: true,
+			errs: []error{&Error{Err: ErrNotFound}}, wantCalls: 1, want: ErrNotFound},
+		{name: "rate limited", opts: opts, idempotent: true,
+			errs: []error{&Error{Err: ErrRateLimited}}, wantCalls: 2},
+		{name: "retry after past the deadline", opts: Options{Timeout: 50 * time.Millisecond, Backoff: time.Millisecond}.withDefaults(), idempotent: true,
+			errs: []error{&Error{Err: ErrRateLimited, RetryAfter: time.Minute}}, wantCalls: 1, want: ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, calls := failing(tt.errs...)
+			start := time.Now()
+			err := call(context.Background(), tt.opts, tt.idempotent, fn)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", *calls, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("returned after %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestCallHonoursRetryAfter(t *testing.T) {
+	opts := Options{Backoff: time.Millisecond}.withDefaults()
+	fn, calls := failing(&Error{Err: ErrRateLimited, RetryAfter: 50 * time.Millisecond})
+
+	start := time.Now()
+	if err := call(context.Background(), opts, true, fn); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retried after %v, want at least the 50ms asked for", elapsed)
+	}
+	if *calls != 2 {
+		t.Errorf("calls = %d, want 2", *calls)
+	}
+}
+
+func TestCallCanceled(t *testing.T) {
+	opts := Options{Backoff: time.Hour, MaxBackoff: time.Hour}.withDefaults()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	fn, calls := failing(&Error{Err: ErrUnavailable})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if err := call(ctx, opts, true, fn); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want the last failure", err)
+	}
+	if *calls != 1 {
+		t.Errorf("calls = %d, want 1", *calls)
+	}
+}
+
+func TestCallDefaultTimeout(t *testing.T) {
+	opts := Options{Timeout: 20 * time.Millisecond}.withDefaults()
+	err := call(context.Background(), opts, false, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}